@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/service"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+const usage = `usage: dlq <list|replay|purge> [flags]
+
+  list    print the dead-lettered messages as JSON
+  replay  move messages back to the queue they came from
+  purge   drop messages for good
+`
+
+// dlq inspects and empties the dead-letter queues of the SMS queues.
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	queue := flags.String("queue", "", "only this SMS queue's dead-letter queue (default all)")
+	id := flags.String("id", "", "only the message with this id (default all)")
+	limit := flags.Int("limit", 100, "most messages to list")
+	_ = flags.Parse(os.Args[2:])
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+
+	logger := logger.New()
+	cfg := config.Load()
+
+	rabbitMQConnection := connection.NewRabbitMQConnection(cfg.RabbitMQ, logger, "dlq-cli", "sms-gateway", service.QueueNameSMSMain)
+	if err := rabbitMQConnection.Connect(); err != nil {
+		logger.Error(ctx, "Failed to connect to RabbitMQ", "error", err.Error())
+		os.Exit(1)
+	}
+	defer rabbitMQConnection.Close()
+
+	queueStrategy := service.NewQueueDistributionStrategy(logger, rabbitMQConnection, cfg.RabbitMQ.PrefetchCount, cfg.RabbitMQ)
+	if err := rabbitMQConnection.DeclareDeadLetterQueues(queueStrategy.GetQueueNames()); err != nil {
+		logger.Error(ctx, "Failed to declare dead-letter queues", "error", err.Error())
+		os.Exit(1)
+	}
+	deadLetterService := service.NewDeadLetterService(rabbitMQConnection, queueStrategy, logger)
+
+	var result any
+	var err error
+	switch command {
+	case "list":
+		result, err = deadLetterService.List(ctx, *queue, *limit)
+	case "replay":
+		var replayed int
+		replayed, err = deadLetterService.Replay(ctx, *queue, *id)
+		result = map[string]int{"replayed": replayed}
+	case "purge":
+		var purged int
+		purged, err = deadLetterService.Purge(ctx, *queue, *id)
+		result = map[string]int{"purged": purged}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Error(ctx, "Failed to "+command+" dead-lettered messages", "error", err.Error())
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		logger.Error(ctx, "Failed to print result", "error", err.Error())
+	}
+}
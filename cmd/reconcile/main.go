@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/repository"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/service"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+// reconcile runs a single reconciliation, prints the report as JSON and exits
+// with status 1 when issues are left unrepaired.
+func main() {
+	repair := flag.Bool("repair", false, "repair the safe cases instead of only reporting them")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer cancel()
+
+	logger := logger.New()
+	cfg := config.Load()
+
+	gormDB, sqlDB := connection.MysqlConnection(ctx, logger, cfg)
+	defer sqlDB.Close()
+
+	reconciliationService := service.NewReconciliationService(
+		repository.NewReconciliationRepository(gormDB, logger),
+		repository.NewSMSRepository(gormDB, logger),
+		repository.NewTransactionRepository(gormDB, logger),
+		logger,
+		cfg.Reconcile,
+	)
+
+	report, err := reconciliationService.Reconcile(ctx, entity.ReconciliationTriggerCLI, *repair)
+	if err != nil {
+		logger.Error(ctx, "Failed to reconcile", "error", err.Error())
+		os.Exit(1)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		logger.Error(ctx, "Failed to print report", "error", err.Error())
+	}
+
+	if report.Issues > report.Repaired {
+		os.Exit(1)
+	}
+}
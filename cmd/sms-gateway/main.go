@@ -2,17 +2,23 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 	"github.com/gin-gonic/gin"
 	"github.com/mohammadghasemi1379/sms-gateway/config"
 	"github.com/mohammadghasemi1379/sms-gateway/connection"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/handler"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/migration"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/ratelimit"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/repository"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/repository/provider"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/service"
@@ -39,7 +45,7 @@ func main() {
 	RabbitMQConnection := connection.NewRabbitMQConnection(cfg.RabbitMQ, logger, "sms-gateway", "sms-gateway", "sms-gateway")
 	err := RabbitMQConnection.Connect()
 	if err != nil {
-		logger.Error(ctx, "Failed to connect to RabbitMQ", err)
+		logger.Panic(ctx, "Failed to connect to RabbitMQ", err)
 	}
 	RabbitMQConnection.ConnectionOpener()
 	defer func(rabbitmqConn *connection.RabbitMQConnection) {
@@ -53,6 +59,11 @@ func main() {
 	if err := RabbitMQConnection.DeclareMultipleQueues(queueStrategy.GetQueueNames()); err != nil {
 		logger.Panic(ctx, "Failed to declare queues", err)
 	}
+	if err := RabbitMQConnection.DeclareDeadLetterQueues(queueStrategy.GetQueueNames()); err != nil {
+		logger.Panic(ctx, "Failed to declare dead-letter queues", err)
+	}
+	queueDepthSampler := service.NewQueueDepthSampler(RabbitMQConnection, queueStrategy.GetQueueNames(), logger, cfg.RabbitMQ)
+	queueStrategy.WithDepthSampler(queueDepthSampler)
 
 	// Run database migrations
 	migrationRunner := migration.NewRunner(gormDB, sqlDB, logger)
@@ -61,15 +72,62 @@ func main() {
 	}
 
 	// Initialize repositories
+	unitOfWork := repository.NewUnitOfWork(gormDB)
 	smsRepository := repository.NewSMSRepository(gormDB, logger)
 	transactionRepository := repository.NewTransactionRepository(gormDB, logger)
 	userRepository := repository.NewUserRepository(gormDB, logger)
+	apiKeyRepository := repository.NewAPIKeyRepository(gormDB, logger)
+	smsStatusEventRepository := repository.NewSMSStatusEventRepository(gormDB, logger)
+	otpRepository := repository.NewOTPRepository(gormDB, logger)
+	ledgerRepository := repository.NewLedgerRepository(gormDB, logger)
+	inboundRouteRepository := repository.NewInboundRouteRepository(gormDB, logger)
+	inboundMessageRepository := repository.NewInboundMessageRepository(gormDB, logger)
+	balanceAlertRepository := repository.NewBalanceAlertRepository(gormDB, logger)
+	reconciliationRepository := repository.NewReconciliationRepository(gormDB, logger)
+	spendingCapRepository := repository.NewSpendingCapRepository(gormDB, logger)
+	outboxRepository := repository.NewOutboxRepository(gormDB, logger)
+
+	var limiter ratelimit.Limiter
+	if cfg.Redis.IsConfigured() {
+		redisClient := connection.RedisConnection(ctx, logger, cfg)
+		defer redisClient.Close()
+		limiter = ratelimit.NewRedisLimiter(redisClient)
+	} else {
+		logger.Warn(ctx, "Redis is not configured, rate limits are enforced per instance")
+		limiter = ratelimit.NewMemoryLimiter()
+	}
+
+	if cfg.OTP.Secret == "" {
+		// Codes hashed under a per-process secret can't be verified by other
+		// instances or after a restart, so this is only fit for development.
+		logger.Warn(ctx, "OTP_SECRET is not configured, using a random secret")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Panic(ctx, "Failed to generate OTP secret", err)
+		}
+		cfg.OTP.Secret = hex.EncodeToString(secret)
+	}
 
 	// Initialize services
-	smsService := service.NewSMSService(smsRepository, userRepository, transactionRepository, RabbitMQConnection, logger, queueStrategy)
-	userService := service.NewUserService(userRepository, transactionRepository)
-	transactionService := service.NewTransactionService(transactionRepository, userRepository, logger)
+	statusBroker := service.NewStatusBroker(smsStatusEventRepository, logger, cfg.RabbitMQ)
+	webhookSender := service.NewWebhookSender(cfg.Inbound.ForwardTimeout)
+	balanceAlertService := service.NewBalanceAlertService(balanceAlertRepository, userRepository, smsRepository, outboxRepository, unitOfWork, statusBroker, webhookSender, logger, cfg.Alert)
+	spendingCapService := service.NewSpendingCapService(spendingCapRepository, userRepository, apiKeyRepository, logger)
+	smsService := service.NewSMSService(smsRepository, userRepository, transactionRepository, RabbitMQConnection, logger, outboxRepository, statusBroker, unitOfWork, ledgerRepository, balanceAlertService, spendingCapService)
+	userService := service.NewUserService(userRepository, transactionRepository, apiKeyRepository, smsRepository, ledgerRepository, unitOfWork)
+	ledgerService := service.NewLedgerService(ledgerRepository, userRepository, unitOfWork, logger)
+	otpService := service.NewOTPService(otpRepository, smsService, limiter, logger, cfg.OTP)
+	inboundService := service.NewInboundService(inboundMessageRepository, inboundRouteRepository, userRepository, logger)
+	inboundForwarder := service.NewInboundForwarder(inboundMessageRepository, userRepository, webhookSender, logger, cfg.Inbound)
+	outboxRelay := service.NewOutboxRelay(outboxRepository, unitOfWork, queueStrategy, logger, cfg.Outbox)
+	expirySweeper := service.NewExpirySweeper(smsRepository, smsService, logger, cfg.Expiry)
+	transactionService := service.NewTransactionService(transactionRepository, userRepository, ledgerRepository, logger)
+	reconciliationService := service.NewReconciliationService(reconciliationRepository, smsRepository, transactionRepository, logger, cfg.Reconcile)
+	reconciler := service.NewReconciler(reconciliationService, logger, cfg.Reconcile)
+	deadLetterService := service.NewDeadLetterService(RabbitMQConnection, queueStrategy, logger)
 	provider := provider.NewMockProvider(logger, cfg)
+	multiQueueConsumer := service.NewMultiQueueConsumer(smsService, transactionService, userService, RabbitMQConnection, provider, logger, cfg.RabbitMQ.PrefetchCount, cfg.RabbitMQ)
+	healthService := service.NewHealthService(sqlDB, RabbitMQConnection, multiQueueConsumer, provider, cfg.Health)
 
 	// Initialize Gin router
 	if cfg.App.IsProduction() {
@@ -78,23 +136,99 @@ func main() {
 		gin.SetMode(gin.DebugMode)
 	}
 	router := gin.Default()
+	router.Use(middleware.ErrorHandler(logger))
+	router.NoRoute(middleware.NotFound())
 
 	// Initialize handlers
 	smsHandler := handler.NewSMSHandler(smsService, logger)
 	userHandler := handler.NewUserHandler(userService, logger)
+	statusStreamHandler := handler.NewStatusStreamHandler(statusBroker, logger)
+	healthHandler := handler.NewHealthHandler(healthService)
+	adminUserHandler := handler.NewAdminUserHandler(userService, ledgerService, logger)
+	otpHandler := handler.NewOTPHandler(otpService, logger)
+	transactionHandler := handler.NewTransactionHandler(transactionService, logger)
+	balanceAlertHandler := handler.NewBalanceAlertHandler(balanceAlertService, logger)
+	spendingCapHandler := handler.NewSpendingCapHandler(spendingCapService, logger)
+	inboundHandler := handler.NewInboundHandler(inboundService, logger)
+	adminInboundRouteHandler := handler.NewAdminInboundRouteHandler(inboundService, logger)
+	adminReconciliationHandler := handler.NewAdminReconciliationHandler(reconciliationService, logger)
+	adminDeadLetterHandler := handler.NewAdminDeadLetterHandler(deadLetterService, logger)
+	auth := middleware.NewAuth(userService, logger)
+
+	rateLimit := middleware.NewRateLimit(limiter, cfg.RateLimit, logger)
 
 	// Setup routes
-	api := router.Group("/api")
+	router.GET("/healthz", healthHandler.Liveness)
+	router.GET("/readyz", healthHandler.Readiness)
+
+	api := router.Group("/api", auth.Optional())
 	{
 		user := api.Group("/user")
 		{
-			user.POST("/create", userHandler.CreateUser)
-			user.POST("/update-credit", userHandler.UpdateCredit)
+			user.POST("/create", rateLimit.Limit("user.create"), userHandler.CreateUser)
+			user.POST("/update-credit", rateLimit.Limit("user.update-credit"), userHandler.UpdateCredit)
+			user.PUT("/webhook", auth.Required(), rateLimit.Limit("user.webhook"), userHandler.SetWebhook)
+			user.PUT("/timezone", auth.Required(), rateLimit.Limit("user.timezone"), userHandler.SetTimezone)
 		}
 		sms := api.Group("/sms")
 		{
-			sms.POST("/send", smsHandler.Send)
-			sms.GET("/history", smsHandler.GetHistory)
+			sms.POST("/send", rateLimit.Limit("sms.send"), smsHandler.Send)
+			sms.GET("/history", rateLimit.Limit("sms.history"), smsHandler.GetHistory)
+			sms.GET("/stream", auth.Required(), rateLimit.Limit("sms.stream"), statusStreamHandler.Stream)
+			sms.POST("/:id/cancel", auth.Required(), rateLimit.Limit("sms.cancel"), smsHandler.Cancel)
+		}
+		transactions := api.Group("/transactions", auth.Required())
+		{
+			transactions.GET("", rateLimit.Limit("transactions.list"), transactionHandler.List)
+			transactions.GET("/export", rateLimit.Limit("transactions.export"), transactionHandler.Export)
+			transactions.GET("/statement", rateLimit.Limit("transactions.statement"), transactionHandler.Statement)
+		}
+		spendingCaps := api.Group("/spending-caps", auth.Required())
+		{
+			spendingCaps.GET("", rateLimit.Limit("spending-caps.list"), spendingCapHandler.List)
+			spendingCaps.PUT("", rateLimit.Limit("spending-caps.set"), spendingCapHandler.Set)
+		}
+		alerts := api.Group("/alerts", auth.Required())
+		{
+			alerts.GET("/balance", rateLimit.Limit("alerts.balance"), balanceAlertHandler.GetSettings)
+			alerts.PUT("/balance", rateLimit.Limit("alerts.balance"), balanceAlertHandler.UpdateSettings)
+			alerts.GET("/balance/history", rateLimit.Limit("alerts.history"), balanceAlertHandler.GetHistory)
+		}
+		otp := api.Group("/otp", auth.Required())
+		{
+			otp.POST("/send", rateLimit.Limit("otp.send"), otpHandler.Send)
+			otp.POST("/verify", rateLimit.Limit("otp.verify"), otpHandler.Verify)
+		}
+		inbound := api.Group("/inbound")
+		{
+			inbound.POST("/callback", middleware.ProviderCallback(cfg.Inbound), inboundHandler.Callback)
+			inbound.GET("/history", auth.Required(), rateLimit.Limit("inbound.history"), inboundHandler.GetHistory)
+		}
+		admin := api.Group("/admin", middleware.AdminOnly(cfg.Admin))
+		{
+			admin.GET("/users", adminUserHandler.List)
+			admin.GET("/users/:id", adminUserHandler.Get)
+			admin.PATCH("/users/:id", adminUserHandler.UpdateProfile)
+			admin.POST("/users/:id/suspend", adminUserHandler.Suspend)
+			admin.POST("/users/:id/reactivate", adminUserHandler.Reactivate)
+			admin.PUT("/users/:id/limits", adminUserHandler.UpdateLimits)
+			admin.PUT("/users/:id/billing", adminUserHandler.UpdateBilling)
+			admin.GET("/billing/outstanding", adminUserHandler.Outstanding)
+			admin.GET("/users/:id/ledger", adminUserHandler.Ledger)
+			admin.GET("/users/:id/ledger/verify", adminUserHandler.VerifyLedger)
+			admin.POST("/users/:id/adjust-credit", adminUserHandler.AdjustCredit)
+			admin.GET("/inbound-routes", adminInboundRouteHandler.List)
+			admin.POST("/inbound-routes", adminInboundRouteHandler.Create)
+			admin.DELETE("/inbound-routes/:id", adminInboundRouteHandler.Delete)
+			admin.GET("/reconciliation/reports", adminReconciliationHandler.List)
+			admin.GET("/reconciliation/reports/:id", adminReconciliationHandler.Get)
+			admin.POST("/reconciliation/run", adminReconciliationHandler.Run)
+			admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+			admin.GET("/dlq", adminDeadLetterHandler.List)
+			admin.POST("/dlq/replay", adminDeadLetterHandler.Replay)
+			admin.POST("/dlq/:id/replay", adminDeadLetterHandler.Replay)
+			admin.DELETE("/dlq", adminDeadLetterHandler.Purge)
+			admin.DELETE("/dlq/:id", adminDeadLetterHandler.Purge)
 		}
 	}
 
@@ -112,8 +246,29 @@ func main() {
 		}
 	}()
 
+	// Start status event fan-out
+	go func() {
+		if err := statusBroker.Run(ctx); err != nil {
+			logger.Error(ctx, "Failed to start status broker", "error", err.Error())
+		}
+	}()
+
+	// Start forwarding inbound messages to user webhooks
+	go inboundForwarder.Run(ctx)
+
+	// Start sampling queue depths for routing
+	go queueDepthSampler.Run(ctx)
+
+	// Start publishing the outbox to the broker
+	go outboxRelay.Run(ctx)
+
+	// Start expiring messages the broker discarded
+	go expirySweeper.Run(ctx)
+
+	// Start cross-checking messages, transactions and balances
+	go reconciler.Run(ctx)
+
 	// Start multi-queue consumer
-	multiQueueConsumer := service.NewMultiQueueConsumer(smsService, transactionService, userService, RabbitMQConnection, provider, logger, cfg.RabbitMQ.PrefetchCount, cfg.RabbitMQ)
     go func() {
         logger.Info(ctx, "Starting multi-queue SMS consumer...")
         if err := multiQueueConsumer.ConsumeAllQueues(ctx); err != nil {
@@ -129,6 +284,10 @@ func main() {
 		"shutdown_time", time.Now(),
 	)
 
+	// Fail readiness first so load balancers stop routing to us
+	healthService.SetShuttingDown()
+	time.Sleep(cfg.Health.ShutdownDrain)
+
 	// Shutdown server gracefully
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
@@ -138,4 +297,10 @@ func main() {
 	} else {
 		logger.Info(shutdownCtx, "Server exited gracefully")
 	}
+
+	// Let low-balance alerts triggered by the last requests go out; a webhook
+	// call gives up after the forward timeout anyway
+	alertCtx, alertCancel := context.WithTimeout(context.Background(), cfg.Inbound.ForwardTimeout)
+	defer alertCancel()
+	balanceAlertService.Wait(alertCtx)
 }
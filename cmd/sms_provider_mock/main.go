@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,7 +23,11 @@ var (
 	reset    = time.Now().Add(time.Minute)
 )
 
-type RequestBody struct {}
+type RequestBody struct {
+	ReceiveNumber  string `json:"receive_number"`
+	Message        string `json:"message"`
+	ValidityPeriod int    `json:"validity_period"`
+}
 
 func handler(c *gin.Context) {
 	mu.Lock()
@@ -34,6 +39,14 @@ func handler(c *gin.Context) {
 		return
 	}
 
+	if req.ReceiveNumber == "" || strings.Trim(req.ReceiveNumber, "+0123456789") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "fail",
+			"message": "invalid receive number",
+		})
+		return
+	}
+
 	if time.Now().After(reset) {
 		requests = 0
 		reset = time.Now().Add(time.Minute)
@@ -68,6 +81,9 @@ func main() {
 	api := router.Group("/mock")
 	{
 		api.POST("/sms", handler)
+		api.GET("/health", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
 	}
 
 	// Create HTTP server
@@ -6,15 +6,25 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Redis    RedisConfig
-	App      AppConfig
-	Log      LogConfig
-	Database DatabaseConfig
-	Mock     MockConfig
-	RabbitMQ RabbitMQConfig
+	Redis     RedisConfig
+	App       AppConfig
+	Log       LogConfig
+	Database  DatabaseConfig
+	Mock      MockConfig
+	RabbitMQ  RabbitMQConfig
+	RateLimit RateLimitConfig
+	Health    HealthConfig
+	Admin     AdminConfig
+	OTP       OTPConfig
+	Inbound   InboundConfig
+	Expiry    ExpiryConfig
+	Alert     AlertConfig
+	Reconcile ReconcileConfig
+	Outbox    OutboxConfig
 }
 
 type RedisConfig struct {
@@ -59,6 +69,136 @@ type RabbitMQConfig struct {
 	PrefetchCount   int
 	PrimaryWeight   int
 	SecondaryWeight int
+	// LaneWorkers is how many messages of each priority ("HIGH", "NORMAL",
+	// "LOW") are processed at once, shared by all queues of that priority.
+	LaneWorkers map[string]int
+	// RetryDelays is the backoff schedule: how long a message waits before
+	// its first, second, ... retry. The last delay repeats for later retries.
+	// An empty schedule disables retries: a failed attempt fails the message.
+	RetryDelays []time.Duration
+	// RetryMaxAttempts is how often a message is tried before it's failed.
+	RetryMaxAttempts int
+	// PublishTimeout is how long a publish waits for the broker to confirm
+	// it.
+	PublishTimeout time.Duration
+	// OverflowStrategy picks the overflow queue once the main queue holds
+	// OverflowThreshold messages: weighted_random, round_robin, least_loaded
+	// or consistent_hash. A threshold of 0 means the prefetch count.
+	OverflowStrategy  string
+	OverflowThreshold int
+	// DepthSampleInterval is how often queue depths are sampled for routing.
+	DepthSampleInterval time.Duration
+	// DepthMaxStaleness is how old a sampled depth may be and still be used
+	// for routing. Older ones are treated as unknown.
+	DepthMaxStaleness time.Duration
+}
+
+type RateLimitConfig struct {
+	Enabled     bool
+	Window      time.Duration
+	DefaultPlan string
+	// Plans maps a plan name to its limits per endpoint. The "*" endpoint
+	// limits all requests of a user, regardless of the endpoint.
+	Plans map[string]map[string]int
+}
+
+type HealthConfig struct {
+	CheckTimeout  time.Duration
+	CheckProvider bool
+	// ShutdownDrain is how long readiness reports unavailable before the
+	// server stops accepting requests, so load balancers can drain us.
+	ShutdownDrain time.Duration
+}
+
+type AdminConfig struct {
+	// APIKey guards the admin API. The admin API is disabled when it's empty.
+	APIKey string
+}
+
+type OTPConfig struct {
+	Length         int
+	TTL            time.Duration
+	MaxAttempts    int
+	ResendCooldown time.Duration
+	// Template is the message sent to the recipient, {code} is replaced by
+	// the generated code.
+	Template string
+	// Secret keys the hash codes are stored under.
+	Secret string
+	// VerifyLimit caps verification requests per recipient per VerifyWindow.
+	VerifyLimit  int
+	VerifyWindow time.Duration
+}
+
+type InboundConfig struct {
+	// CallbackKey guards the provider callback endpoint. Callbacks are
+	// rejected when it's empty.
+	CallbackKey string
+	// ForwardInterval is how often pending messages are forwarded to user
+	// webhooks.
+	ForwardInterval    time.Duration
+	ForwardTimeout     time.Duration
+	ForwardBatchSize   int
+	ForwardMaxAttempts int
+	// ForwardRetryDelay is the delay before the first retry, doubled after
+	// each further failed attempt.
+	ForwardRetryDelay time.Duration
+}
+
+type ExpiryConfig struct {
+	// SweepInterval is how often pending messages past their validity are
+	// looked for. The broker discards such messages, so nothing else would
+	// close them.
+	SweepInterval time.Duration
+	// SweepGrace is how long after expiry a message is left to the consumer
+	// before the sweeper expires it.
+	SweepGrace     time.Duration
+	SweepBatchSize int
+}
+
+type AlertConfig struct {
+	// Cooldown is the minimum time between two low-balance alerts to the
+	// same user.
+	Cooldown time.Duration
+	// SMSTemplate is the alert sent by SMS, {balance} and {threshold} are
+	// replaced by the user's credit and alert threshold.
+	SMSTemplate string
+}
+
+type OutboxConfig struct {
+	// PollInterval is how often the relay looks for messages to publish.
+	PollInterval time.Duration
+	// BatchSize caps the messages one relay pass publishes.
+	BatchSize int
+	// RetryDelay is how long a message waits after its first failed publish;
+	// it doubles with every further failure up to MaxRetryDelay. Messages
+	// are never given up on, as they have been paid for.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// Retention is how long dispatched messages are kept.
+	Retention time.Duration
+	// FairQuantum is how many messages a user of weight 1 may publish per
+	// round of the fair scheduler; PlanWeights weighs users by plan, 1 for
+	// plans not listed.
+	FairQuantum int
+	PlanWeights map[string]int
+	// MaxQueueDepth holds normal and low priority messages back in the
+	// outbox, where they are scheduled fairly, while the SMS queues together
+	// hold this many messages. 0 publishes them as fast as possible.
+	MaxQueueDepth int
+}
+
+type ReconcileConfig struct {
+	// Interval is how often messages, transactions and balances are
+	// cross-checked. Scheduled runs are disabled when it's zero.
+	Interval time.Duration
+	// Grace is how long a message is left alone after its last update, so
+	// messages still being processed aren't reported.
+	Grace time.Duration
+	// BatchSize caps the findings of each check per run.
+	BatchSize int
+	// AutoRepair makes scheduled runs repair the safe cases.
+	AutoRepair bool
 }
 
 type ThrottleConfig struct {
@@ -70,18 +210,29 @@ type ThrottleConfig struct {
 func Load() *Config {
 	godotenv.Load(".env")
 	return &Config{
-		Redis:    loadRedisConfig(),
-		App:      loadAppConfig(),
-		Log:      loadLogConfig(),
-		Database: loadDatabaseConfig(),
-		Mock:     loadMockConfig(),
-		RabbitMQ: loadRabbitMQConfig(),
+		Redis:     loadRedisConfig(),
+		App:       loadAppConfig(),
+		Log:       loadLogConfig(),
+		Database:  loadDatabaseConfig(),
+		Mock:      loadMockConfig(),
+		RabbitMQ:  loadRabbitMQConfig(),
+		RateLimit: loadRateLimitConfig(),
+		Health:    loadHealthConfig(),
+		Admin:     loadAdminConfig(),
+		OTP:       loadOTPConfig(),
+		Inbound:   loadInboundConfig(),
+		Expiry:    loadExpiryConfig(),
+		Alert:     loadAlertConfig(),
+		Reconcile: loadReconcileConfig(),
+		Outbox:    loadOutboxConfig(),
 	}
 }
 
+// loadRedisConfig leaves Host empty unless REDIS_HOST is set; it used to
+// default to "localhost". Without a host, rate limits are kept per instance.
 func loadRedisConfig() RedisConfig {
 	return RedisConfig{
-		Host:     getEnv("REDIS_HOST", "localhost"),
+		Host:     getEnv("REDIS_HOST", ""),
 		Port:     getEnvAsInt("REDIS_PORT", 6379),
 		Password: getEnv("REDIS_PASSWORD", ""),
 		DB:       getEnvAsInt("REDIS_DB", 0),
@@ -124,15 +275,187 @@ func loadMockConfig() MockConfig {
 
 func loadRabbitMQConfig() RabbitMQConfig {
 	return RabbitMQConfig{
-		Host:            getEnv("RABBITMQ_HOST", "localhost"),
-		Port:            getEnvAsInt("RABBITMQ_PORT", 5672),
-		User:            getEnv("RABBITMQ_USER", "guest"),
-		Password:        getEnv("RABBITMQ_PASSWORD", "guest"),
-		VHost:           getEnv("RABBITMQ_VHOST", "/"),
-		PrefetchCount:   getEnvAsInt("RABBITMQ_PREFETCH_COUNT", 10),
-		PrimaryWeight:   getEnvAsInt("RABBITMQ_PRIMARY_WEIGHT", 90),
-		SecondaryWeight: getEnvAsInt("RABBITMQ_SECONDARY_WEIGHT", 10),
+		Host:                getEnv("RABBITMQ_HOST", "localhost"),
+		Port:                getEnvAsInt("RABBITMQ_PORT", 5672),
+		User:                getEnv("RABBITMQ_USER", "guest"),
+		Password:            getEnv("RABBITMQ_PASSWORD", "guest"),
+		VHost:               getEnv("RABBITMQ_VHOST", "/"),
+		PrefetchCount:       getEnvAsInt("RABBITMQ_PREFETCH_COUNT", 10),
+		PrimaryWeight:       getEnvAsInt("RABBITMQ_PRIMARY_WEIGHT", 90),
+		SecondaryWeight:     getEnvAsInt("RABBITMQ_SECONDARY_WEIGHT", 10),
+		LaneWorkers:         parseLaneWorkers(getEnv("RABBITMQ_LANE_WORKERS", "HIGH=8,NORMAL=8,LOW=2")),
+		RetryDelays:         parseDelays(getEnv("RABBITMQ_RETRY_DELAYS_SECONDS", "5,30,120,600")),
+		RetryMaxAttempts:    getEnvAsInt("RABBITMQ_RETRY_MAX_ATTEMPTS", 5),
+		PublishTimeout:      time.Duration(getEnvAsInt("RABBITMQ_PUBLISH_TIMEOUT_MS", 5000)) * time.Millisecond,
+		OverflowStrategy:    getEnv("RABBITMQ_OVERFLOW_STRATEGY", "weighted_random"),
+		OverflowThreshold:   getEnvAsInt("RABBITMQ_OVERFLOW_THRESHOLD", 0),
+		DepthSampleInterval: time.Duration(getEnvAsInt("RABBITMQ_DEPTH_SAMPLE_INTERVAL_MS", 1000)) * time.Millisecond,
+		DepthMaxStaleness:   time.Duration(getEnvAsInt("RABBITMQ_DEPTH_MAX_STALENESS_MS", 5000)) * time.Millisecond,
+	}
+}
+
+func loadRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Enabled:     getEnvAsBool("RATE_LIMIT_ENABLED", true),
+		Window:      time.Duration(getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 60)) * time.Second,
+		DefaultPlan: getEnv("RATE_LIMIT_DEFAULT_PLAN", "basic"),
+		Plans:       parseRateLimitPlans(getEnv("RATE_LIMIT_PLANS", "basic:*=300,basic:sms.send=120,premium:*=3000,premium:sms.send=1200")),
+	}
+}
+
+func loadHealthConfig() HealthConfig {
+	return HealthConfig{
+		CheckTimeout:  time.Duration(getEnvAsInt("HEALTH_CHECK_TIMEOUT_MS", 2000)) * time.Millisecond,
+		CheckProvider: getEnvAsBool("HEALTH_CHECK_PROVIDER", false),
+		ShutdownDrain: time.Duration(getEnvAsInt("HEALTH_SHUTDOWN_DRAIN_SECONDS", 5)) * time.Second,
+	}
+}
+
+func loadAdminConfig() AdminConfig {
+	return AdminConfig{
+		APIKey: getEnv("ADMIN_API_KEY", ""),
+	}
+}
+
+func loadOTPConfig() OTPConfig {
+	return OTPConfig{
+		Length:         getEnvAsInt("OTP_LENGTH", 6),
+		TTL:            time.Duration(getEnvAsInt("OTP_TTL_SECONDS", 120)) * time.Second,
+		MaxAttempts:    getEnvAsInt("OTP_MAX_ATTEMPTS", 5),
+		ResendCooldown: time.Duration(getEnvAsInt("OTP_RESEND_COOLDOWN_SECONDS", 60)) * time.Second,
+		Template:       getEnv("OTP_TEMPLATE", "Your verification code is {code}"),
+		Secret:         getEnv("OTP_SECRET", ""),
+		VerifyLimit:    getEnvAsInt("OTP_VERIFY_LIMIT", 10),
+		VerifyWindow:   time.Duration(getEnvAsInt("OTP_VERIFY_WINDOW_SECONDS", 600)) * time.Second,
+	}
+}
+
+func loadInboundConfig() InboundConfig {
+	return InboundConfig{
+		CallbackKey:        getEnv("INBOUND_CALLBACK_KEY", ""),
+		ForwardInterval:    time.Duration(getEnvAsInt("INBOUND_FORWARD_INTERVAL_SECONDS", 2)) * time.Second,
+		ForwardTimeout:     time.Duration(getEnvAsInt("INBOUND_FORWARD_TIMEOUT_SECONDS", 5)) * time.Second,
+		ForwardBatchSize:   getEnvAsInt("INBOUND_FORWARD_BATCH_SIZE", 50),
+		ForwardMaxAttempts: getEnvAsInt("INBOUND_FORWARD_MAX_ATTEMPTS", 8),
+		ForwardRetryDelay:  time.Duration(getEnvAsInt("INBOUND_FORWARD_RETRY_DELAY_SECONDS", 10)) * time.Second,
+	}
+}
+
+func loadExpiryConfig() ExpiryConfig {
+	return ExpiryConfig{
+		SweepInterval:  time.Duration(getEnvAsInt("SMS_EXPIRY_SWEEP_INTERVAL_SECONDS", 30)) * time.Second,
+		SweepGrace:     time.Duration(getEnvAsInt("SMS_EXPIRY_SWEEP_GRACE_SECONDS", 60)) * time.Second,
+		SweepBatchSize: getEnvAsInt("SMS_EXPIRY_SWEEP_BATCH_SIZE", 100),
+	}
+}
+
+func loadAlertConfig() AlertConfig {
+	return AlertConfig{
+		Cooldown:    time.Duration(getEnvAsInt("BALANCE_ALERT_COOLDOWN_SECONDS", 86400)) * time.Second,
+		SMSTemplate: getEnv("BALANCE_ALERT_SMS_TEMPLATE", "Your credit is {balance}, below your alert threshold of {threshold}. Top up to keep sending messages."),
+	}
+}
+
+func loadReconcileConfig() ReconcileConfig {
+	return ReconcileConfig{
+		Interval:   time.Duration(getEnvAsInt("RECONCILE_INTERVAL_SECONDS", 3600)) * time.Second,
+		Grace:      time.Duration(getEnvAsInt("RECONCILE_GRACE_SECONDS", 600)) * time.Second,
+		BatchSize:  getEnvAsInt("RECONCILE_BATCH_SIZE", 500),
+		AutoRepair: getEnvAsBool("RECONCILE_AUTO_REPAIR", true),
+	}
+}
+
+func loadOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		PollInterval:  time.Duration(getEnvAsInt("OUTBOX_POLL_INTERVAL_MS", 200)) * time.Millisecond,
+		BatchSize:     getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+		RetryDelay:    time.Duration(getEnvAsInt("OUTBOX_RETRY_DELAY_SECONDS", 1)) * time.Second,
+		MaxRetryDelay: time.Duration(getEnvAsInt("OUTBOX_MAX_RETRY_DELAY_SECONDS", 60)) * time.Second,
+		Retention:     time.Duration(getEnvAsInt("OUTBOX_RETENTION_HOURS", 72)) * time.Hour,
+		FairQuantum:   getEnvAsInt("OUTBOX_FAIR_QUANTUM", 10),
+		PlanWeights:   parsePlanWeights(getEnv("OUTBOX_PLAN_WEIGHTS", "basic=1,premium=4")),
+		MaxQueueDepth: getEnvAsInt("OUTBOX_MAX_QUEUE_DEPTH", 1000),
+	}
+}
+
+// parseDelays parses a comma separated list of seconds, e.g. "5,30,120".
+// Malformed or non-positive values are ignored.
+func parseDelays(value string) []time.Duration {
+	var delays []time.Duration
+	for _, secondsValue := range strings.Split(value, ",") {
+		seconds, err := strconv.Atoi(strings.TrimSpace(secondsValue))
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		delays = append(delays, time.Duration(seconds)*time.Second)
+	}
+	return delays
+}
+
+// parseLaneWorkers parses "priority=workers" pairs separated by commas, e.g.
+// "HIGH=8,NORMAL=8,LOW=2". Malformed pairs are ignored.
+func parseLaneWorkers(value string) map[string]int {
+	workers := make(map[string]int)
+	for _, lane := range strings.Split(value, ",") {
+		priority, countValue, ok := strings.Cut(strings.TrimSpace(lane), "=")
+		if !ok {
+			continue
+		}
+		count, err := strconv.Atoi(countValue)
+		if err != nil || count <= 0 {
+			continue
+		}
+		workers[strings.ToUpper(priority)] = count
 	}
+	return workers
+}
+
+// parsePlanWeights parses "plan=weight" pairs separated by commas, e.g.
+// "basic=1,premium=4". Malformed pairs are ignored.
+func parsePlanWeights(value string) map[string]int {
+	weights := make(map[string]int)
+	for _, pair := range strings.Split(value, ",") {
+		plan, weightValue, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		weight, err := strconv.Atoi(weightValue)
+		if err != nil || weight <= 0 {
+			continue
+		}
+		weights[plan] = weight
+	}
+	return weights
+}
+
+// parseRateLimitPlans parses "plan:endpoint=limit" pairs separated by commas,
+// e.g. "basic:*=300,basic:sms.send=120". Malformed pairs are ignored.
+func parseRateLimitPlans(value string) map[string]map[string]int {
+	plans := make(map[string]map[string]int)
+	for _, rule := range strings.Split(value, ",") {
+		key, limitValue, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			continue
+		}
+		plan, endpoint, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		limit, err := strconv.Atoi(limitValue)
+		if err != nil {
+			continue
+		}
+		if plans[plan] == nil {
+			plans[plan] = make(map[string]int)
+		}
+		plans[plan][endpoint] = limit
+	}
+	return plans
+}
+
+// IsConfigured reports whether a Redis host has been set.
+func (r *RedisConfig) IsConfigured() bool {
+	return r.Host != ""
 }
 
 func (r *RedisConfig) GetRedisAddr() string {
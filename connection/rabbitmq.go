@@ -2,6 +2,8 @@ package connection
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
@@ -18,24 +20,75 @@ type RabbitMQMessageBody struct {
 	Data      []byte
 	Type      string
 	RequestID string
+	// Expiration makes the broker discard the message once it has waited in
+	// a queue this long. Zero means it never expires.
+	Expiration time.Duration
+	// Attempts is how often the message was tried already, carried in the
+	// HeaderAttempts header.
+	Attempts int
+}
+
+const (
+	// HeaderAttempts is the message header counting delivery attempts.
+	HeaderAttempts = "x-attempts"
+	// HeaderOriginalQueue and HeaderDeadLetterReason record where a
+	// dead-lettered message came from and why it was parked.
+	HeaderOriginalQueue    = "x-original-queue"
+	HeaderDeadLetterReason = "x-dead-letter-reason"
+
+	// DeadLetterExchange routes parked messages to the dead-letter queue of
+	// the queue they came from, by its name.
+	DeadLetterExchange = "sms-gateway-dlx"
+)
+
+var (
+	// ErrPublishNotConfirmed means the broker didn't confirm a publish, so
+	// the message may not have been stored.
+	ErrPublishNotConfirmed = errors.New("rabbitmq did not confirm the message")
+	// ErrMessageUnroutable means the broker confirmed a publish but no queue
+	// took the message.
+	ErrMessageUnroutable = errors.New("rabbitmq could not route the message to a queue")
+)
+
+// DeadLetterQueueName returns the dead-letter queue of a queue.
+func DeadLetterQueueName(queue string) string {
+	return queue + ".dlq"
+}
+
+// DeliveryAttempts returns the attempts recorded in a delivery's headers.
+func DeliveryAttempts(delivery amqp.Delivery) int {
+	switch attempts := delivery.Headers[HeaderAttempts].(type) {
+	case int32:
+		return int(attempts)
+	case int64:
+		return int(attempts)
+	case int:
+		return attempts
+	default:
+		return 0
+	}
 }
 
 type RabbitMQMessage struct {
+	Exchange    string
 	Queue       string
 	ContentType string
 	Body        RabbitMQMessageBody
 }
 
 type RabbitMQConnection struct {
-	name      string
-	conn      *amqp.Connection
-	channel   *amqp.Channel
-	exchange  string
-	queue     string
-	err       chan error
-	connected chan struct{}
-	config    config.RabbitMQConfig
-	logger    *logger.Logger
+	name           string
+	conn           *amqp.Connection
+	channel        *amqp.Channel
+	exchange       string
+	queue          string
+	exclusiveQueue bool
+	workerPool     chan struct{}
+	returns        *returnTracker
+	err            chan error
+	connected      chan struct{}
+	config         config.RabbitMQConfig
+	logger         *logger.Logger
 }
 
 func NewRabbitMQConnection(config config.RabbitMQConfig, logger *logger.Logger, connectionName, exchange, queue string) *RabbitMQConnection {
@@ -50,6 +103,21 @@ func NewRabbitMQConnection(config config.RabbitMQConfig, logger *logger.Logger,
 	}
 }
 
+// WithExclusiveQueue makes the connection declare its queue as exclusive and
+// auto-delete, so the queue lives only as long as this connection does.
+func (c *RabbitMQConnection) WithExclusiveQueue() *RabbitMQConnection {
+	c.exclusiveQueue = true
+	return c
+}
+
+// WithWorkerPool makes the connection process deliveries on workers taken
+// from pool, which can be shared between connections to cap their combined
+// concurrency. Without it each connection has one worker per CPU.
+func (c *RabbitMQConnection) WithWorkerPool(pool chan struct{}) *RabbitMQConnection {
+	c.workerPool = pool
+	return c
+}
+
 func (c *RabbitMQConnection) Connect() error {
 	var err error
 
@@ -98,6 +166,18 @@ func (c *RabbitMQConnection) Connect() error {
 		)
 		return err
 	}
+	// Confirm mode makes the broker ack or nack every publish, and publishing
+	// as mandatory makes it return what no queue took.
+	if err = c.channel.Confirm(false); err != nil {
+		c.logger.Error(
+			context.TODO(),
+			"error in enabling publisher confirms",
+			"error", err,
+		)
+		return err
+	}
+	c.returns = newReturnTracker(c.channel.NotifyReturn(make(chan amqp.Return)))
+
 	if err = c.channel.ExchangeDeclare(
 		c.exchange,
 		"direct",
@@ -202,8 +282,10 @@ func (c *RabbitMQConnection) Consume(prefetchCount int) (<-chan amqp.Delivery, e
 }
 
 func (c *RabbitMQConnection) HandleConsumedDeliveries(ctx context.Context, prefetchCount int, fn func(context.Context, *logger.Logger, RabbitMQConnection, amqp.Delivery)) {
-	workerPoolSize := runtime.NumCPU()
-	workerPool := make(chan struct{}, workerPoolSize)
+	workerPool := c.workerPool
+	if workerPool == nil {
+		workerPool = make(chan struct{}, runtime.NumCPU())
+	}
 	for {
 		select {
 		case <-ctx.Done():
@@ -244,17 +326,17 @@ func (c *RabbitMQConnection) processDeliveriesWithWorkerPool(ctx context.Context
 						"panic", r,
 					)
 
-					err := delivery.Reject(false)
+					err := c.DeadLetter(delivery, fmt.Sprintf("panic: %v", r))
 					if err != nil {
 						c.logger.Error(
 							context.TODO(),
-							"failed to Reject",
+							"failed to dead-letter",
 							"error", err,
 						)
 					} else {
 						c.logger.Info(
 							context.TODO(),
-							"reject message after we faced panic",
+							"dead-lettered message after we faced panic",
 						)
 					}
 				}
@@ -282,11 +364,14 @@ func (c *RabbitMQConnection) Publish(ctx context.Context, message RabbitMQMessag
 		ContentType: message.ContentType,
 		Body:        message.Body.Data,
 	}
+	if message.Body.Attempts > 0 {
+		publishing.Headers[HeaderAttempts] = int32(message.Body.Attempts)
+	}
+	if message.Body.Expiration > 0 {
+		publishing.Expiration = strconv.FormatInt(max(message.Body.Expiration.Milliseconds(), 1), 10)
+	}
 
-	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-	defer cancel()
-
-	if err := c.channel.PublishWithContext(ctx, "", message.Queue, false, false, publishing); err != nil {
+	if err := c.publishConfirmed(ctx, message.Exchange, message.Queue, publishing); err != nil {
 		c.logger.Error(
 			context.TODO(),
 			"error in Publishing",
@@ -298,8 +383,60 @@ func (c *RabbitMQConnection) Publish(ctx context.Context, message RabbitMQMessag
 	return nil
 }
 
+// publishConfirmed publishes a mandatory message and waits for the broker to
+// confirm it, failing when the broker nacks or returns it or doesn't answer
+// within the publish timeout.
+func (c *RabbitMQConnection) publishConfirmed(ctx context.Context, exchange, key string, publishing amqp.Publishing) error {
+	return confirmPublish(ctx, c.channel, c.returns, c.config.PublishTimeout, exchange, key, publishing)
+}
+
+func confirmPublish(
+	ctx context.Context,
+	channel *amqp.Channel,
+	returns *returnTracker,
+	timeout time.Duration,
+	exchange, key string,
+	publishing amqp.Publishing,
+) error {
+	if publishing.MessageId == "" {
+		publishing.MessageId = newMessageID()
+	}
+	returned := returns.expect(publishing.MessageId)
+	defer returns.forget(publishing.MessageId)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, publishing)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPublishNotConfirmed, err)
+	}
+	if !acked {
+		return fmt.Errorf("%w: nacked by the broker", ErrPublishNotConfirmed)
+	}
+
+	// The broker returns a message before confirming it, so by now any
+	// return is known.
+	returns.flush()
+	select {
+	case ret := <-returned:
+		return fmt.Errorf("%w: %s (%d) for exchange %q and routing key %q", ErrMessageUnroutable, ret.ReplyText, ret.ReplyCode, exchange, key)
+	default:
+		return nil
+	}
+}
+
 func (c *RabbitMQConnection) BindQueue() error {
-	if _, err := c.channel.QueueDeclare(c.queue, true, false, false, false, nil); err != nil {
+	durable, autoDelete, exclusive := true, false, false
+	if c.exclusiveQueue {
+		durable, autoDelete, exclusive = false, true, true
+	}
+	if _, err := c.channel.QueueDeclare(c.queue, durable, autoDelete, exclusive, false, nil); err != nil {
 		c.logger.Error(
 			context.TODO(),
 			"error in declaring the queue",
@@ -341,6 +478,13 @@ func (c *RabbitMQConnection) Close() error {
 	return nil
 }
 
+// IsConnected reports whether both the connection and its channel are open.
+func (c *RabbitMQConnection) IsConnected() bool {
+	if c == nil || c.conn == nil || c.channel == nil {
+		return false
+	}
+	return !c.conn.IsClosed() && !c.channel.IsClosed()
+}
 
 func (c *RabbitMQConnection) GetQueueMessageCount() (int, error) {
 	if c.channel == nil {
@@ -371,3 +515,146 @@ func (c *RabbitMQConnection) DeclareMultipleQueues(queueNames []string) error {
 	}
 	return nil
 }
+
+// DeclareDelayQueue declares a fanout exchange and a queue of the same name
+// behind it. The queue holds messages for delay and then dead-letters them to
+// the default exchange under the routing key they were published with, so
+// publishing to the exchange with a queue's name as routing key redelivers the
+// message to that queue after the delay.
+func (c *RabbitMQConnection) DeclareDelayQueue(name string, delay time.Duration) error {
+	if err := c.channel.ExchangeDeclare(name, "fanout", true, false, false, false, nil); err != nil {
+		c.logger.Error(context.TODO(), "error in declaring delay exchange", "exchange", name, "error", err.Error())
+		return err
+	}
+
+	args := amqp.Table{
+		"x-message-ttl":          delay.Milliseconds(),
+		"x-dead-letter-exchange": "",
+	}
+	if _, err := c.channel.QueueDeclare(name, true, false, false, false, args); err != nil {
+		c.logger.Error(context.TODO(), "error in declaring delay queue", "queue", name, "error", err.Error())
+		return err
+	}
+
+	if err := c.channel.QueueBind(name, "", name, false, nil); err != nil {
+		c.logger.Error(context.TODO(), "error in binding delay queue", "queue", name, "error", err.Error())
+		return err
+	}
+
+	c.logger.Info(context.TODO(), "Delay queue declared", "queue", name, "delay", delay.String())
+	return nil
+}
+
+// DeclareDeadLetterQueues declares the dead-letter exchange and a dead-letter
+// queue for each of queueNames. Messages are parked explicitly by DeadLetter
+// rather than through queue arguments, which can't be added to queues that
+// already exist.
+func (c *RabbitMQConnection) DeclareDeadLetterQueues(queueNames []string) error {
+	if err := c.channel.ExchangeDeclare(DeadLetterExchange, "direct", true, false, false, false, nil); err != nil {
+		c.logger.Error(context.TODO(), "error in declaring dead-letter exchange", "error", err.Error())
+		return err
+	}
+
+	for _, queueName := range queueNames {
+		deadLetterQueue := DeadLetterQueueName(queueName)
+		if _, err := c.channel.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+			c.logger.Error(context.TODO(), "error in declaring dead-letter queue", "queue", deadLetterQueue, "error", err.Error())
+			return err
+		}
+		if err := c.channel.QueueBind(deadLetterQueue, queueName, DeadLetterExchange, false, nil); err != nil {
+			c.logger.Error(context.TODO(), "error in binding dead-letter queue", "queue", deadLetterQueue, "error", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// DeadLetter parks a delivery of the connection's queue in its dead-letter
+// queue along with reason, then acks it once the broker confirmed the copy.
+// When parking fails the delivery is rejected without requeueing, as
+// redelivering it would fail the same way.
+func (c *RabbitMQConnection) DeadLetter(delivery amqp.Delivery, reason string) error {
+	headers := amqp.Table{}
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+	headers[HeaderOriginalQueue] = c.queue
+	headers[HeaderDeadLetterReason] = reason
+
+	messageID := delivery.MessageId
+	if messageID == "" {
+		messageID = newMessageID()
+	}
+
+	err := c.publishConfirmed(context.Background(), DeadLetterExchange, c.queue, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  delivery.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Timestamp:    time.Now(),
+		Body:         delivery.Body,
+	})
+	if err != nil {
+		if rejectErr := delivery.Reject(false); rejectErr != nil {
+			c.logger.Error(context.TODO(), "failed to Reject", "error", rejectErr.Error())
+		}
+		return err
+	}
+	return delivery.Ack(false)
+}
+
+// WithChannel runs fn on a channel of its own, which is closed afterwards.
+// Messages fn got but didn't ack are returned to their queue.
+func (c *RabbitMQConnection) WithChannel(fn func(channel *amqp.Channel) error) error {
+	channel, err := c.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return fn(channel)
+}
+
+// Channel opens a channel of its own on the connection, for work that must
+// not disturb the shared one. The caller closes it.
+func (c *RabbitMQConnection) Channel() (*amqp.Channel, error) {
+	if c == nil || c.conn == nil || c.conn.IsClosed() {
+		return nil, errors.New("rabbitmq connection is closed")
+	}
+	return c.conn.Channel()
+}
+
+// ConfirmedChannel is a channel of its own in confirm mode, whose Publish
+// waits for the broker like the connection's publishes do.
+type ConfirmedChannel struct {
+	*amqp.Channel
+	returns *returnTracker
+	timeout time.Duration
+}
+
+// Publish publishes a mandatory message and waits for the broker to confirm
+// it, failing when the broker nacks or returns it or doesn't answer within
+// the publish timeout.
+func (ch *ConfirmedChannel) Publish(ctx context.Context, exchange, key string, publishing amqp.Publishing) error {
+	return confirmPublish(ctx, ch.Channel, ch.returns, ch.timeout, exchange, key, publishing)
+}
+
+// WithConfirmedChannel is WithChannel with the channel in confirm mode.
+func (c *RabbitMQConnection) WithConfirmedChannel(fn func(channel *ConfirmedChannel) error) error {
+	return c.WithChannel(func(channel *amqp.Channel) error {
+		if err := channel.Confirm(false); err != nil {
+			return err
+		}
+		return fn(&ConfirmedChannel{
+			Channel: channel,
+			returns: newReturnTracker(channel.NotifyReturn(make(chan amqp.Return))),
+			timeout: c.config.PublishTimeout,
+		})
+	})
+}
+
+func newMessageID() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,78 @@
+package connection
+
+import (
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// returnTracker hands the messages a channel returned as unroutable to the
+// publishers waiting on them, matched by message id.
+type returnTracker struct {
+	mu      sync.Mutex
+	pending map[string]chan amqp.Return
+	flushes chan chan struct{}
+	done    chan struct{}
+}
+
+func newReturnTracker(returns chan amqp.Return) *returnTracker {
+	t := &returnTracker{
+		pending: make(map[string]chan amqp.Return),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run(returns)
+	return t
+}
+
+func (t *returnTracker) run(returns chan amqp.Return) {
+	defer close(t.done)
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return
+			}
+			t.mu.Lock()
+			if returned, ok := t.pending[ret.MessageId]; ok {
+				// Never block while holding mu: if two publishes share a
+				// message id, the second return finds the buffer full.
+				select {
+				case returned <- ret:
+				default:
+				}
+			}
+			t.mu.Unlock()
+		case flushed := <-t.flushes:
+			close(flushed)
+		}
+	}
+}
+
+// expect registers a message about to be published and returns where it will
+// show up should the broker return it.
+func (t *returnTracker) expect(messageID string) <-chan amqp.Return {
+	returned := make(chan amqp.Return, 1)
+	t.mu.Lock()
+	t.pending[messageID] = returned
+	t.mu.Unlock()
+	return returned
+}
+
+func (t *returnTracker) forget(messageID string) {
+	t.mu.Lock()
+	delete(t.pending, messageID)
+	t.mu.Unlock()
+}
+
+// flush waits until every return the channel has handed over so far has been
+// passed on. The channel hands a return over before it delivers the confirm
+// of the same message, so after a confirm this makes its return visible.
+func (t *returnTracker) flush() {
+	flushed := make(chan struct{})
+	select {
+	case t.flushes <- flushed:
+		<-flushed
+	case <-t.done:
+	}
+}
@@ -0,0 +1,30 @@
+package connection
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+func RedisConnection(ctx context.Context, logger *logger.Logger, cfg *config.Config) *redis.Client {
+	rc := cfg.Redis
+	client := redis.NewClient(&redis.Options{
+		Addr:     rc.GetRedisAddr(),
+		Password: rc.Password,
+		DB:       rc.DB,
+	})
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(pingCtx).Err(); err != nil {
+		logger.Panic(ctx, "failed to connect to Redis", err)
+	}
+
+	logger.Info(ctx, "Connected to Redis")
+
+	return client
+}
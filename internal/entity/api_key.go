@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+type APIKey struct {
+	ID        uint64     `json:"id"`
+	UserID    uint64     `json:"user_id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	KeyHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,36 @@
+package entity
+
+import "time"
+
+// BalanceAlertSetting configures when and how a user is told their credit is
+// running low.
+type BalanceAlertSetting struct {
+	UserID        uint64     `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Threshold     int64      `json:"threshold"` // alert once the credit drops below this
+	NotifySMS     bool       `json:"notify_sms"`
+	NotifyWebhook bool       `json:"notify_webhook"`
+	Enabled       bool       `json:"enabled"`
+	LastAlertedAt *time.Time `json:"last_alerted_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type BalanceAlertWebhookStatusEnum string
+
+const (
+	BalanceAlertWebhookSent    BalanceAlertWebhookStatusEnum = "SENT"
+	BalanceAlertWebhookFailed  BalanceAlertWebhookStatusEnum = "FAILED"
+	BalanceAlertWebhookSkipped BalanceAlertWebhookStatusEnum = "SKIPPED"
+)
+
+// BalanceAlert is a low-balance alert that went out to a user.
+type BalanceAlert struct {
+	ID            uint64                         `json:"id"`
+	UserID        uint64                         `json:"user_id" gorm:"not null"`
+	Threshold     int64                          `json:"threshold"`
+	Balance       int64                          `json:"balance"`
+	SMSID         *uint64                        `json:"sms_id,omitempty"`
+	WebhookStatus *BalanceAlertWebhookStatusEnum `json:"webhook_status,omitempty"` // nil when webhook alerts are off
+	WebhookError  string                         `json:"webhook_error,omitempty"`
+	CreatedAt     time.Time                      `json:"created_at"`
+}
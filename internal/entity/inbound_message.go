@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+type InboundForwardStatusEnum string
+
+const (
+	// InboundForwardPending messages wait to be forwarded to the user's webhook.
+	InboundForwardPending   InboundForwardStatusEnum = "PENDING"
+	InboundForwardDelivered InboundForwardStatusEnum = "DELIVERED"
+	InboundForwardFailed    InboundForwardStatusEnum = "FAILED"
+	// InboundForwardSkipped messages have no owner or the owner has no webhook.
+	InboundForwardSkipped InboundForwardStatusEnum = "SKIPPED"
+)
+
+// InboundMessage is a mobile-originated message received from a provider.
+type InboundMessage struct {
+	ID                uint64                   `json:"id"`
+	UserID            *uint64                  `json:"user_id"` // nil when no route matched
+	Provider          string                   `json:"provider" gorm:"not null"`
+	ProviderMessageID string                   `json:"provider_message_id" gorm:"not null"`
+	FromNumber        string                   `json:"from_number" gorm:"not null"`
+	ToNumber          string                   `json:"to_number" gorm:"not null"`
+	Keyword           string                   `json:"keyword"`
+	Message           string                   `json:"message" gorm:"not null"`
+	ForwardStatus     InboundForwardStatusEnum `json:"forward_status" gorm:"not null;default:PENDING"`
+	ForwardAttempts   int                      `json:"forward_attempts"`
+	ForwardError      string                   `json:"forward_error,omitempty"`
+	NextForwardAt     *time.Time               `json:"-"`
+	ForwardedAt       *time.Time               `json:"forwarded_at,omitempty"`
+	ReceivedAt        time.Time                `json:"received_at"`
+	CreatedAt         time.Time                `json:"created_at"`
+	UpdatedAt         time.Time                `json:"updated_at"`
+}
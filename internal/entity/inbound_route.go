@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// InboundRoute assigns messages sent to Number to a user. A route with a
+// Keyword only matches messages starting with that keyword and wins over the
+// number's catch-all route, which has an empty Keyword.
+type InboundRoute struct {
+	ID        uint64    `json:"id"`
+	UserID    uint64    `json:"user_id" gorm:"not null"`
+	Number    string    `json:"number" gorm:"not null"`
+	Keyword   string    `json:"keyword"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
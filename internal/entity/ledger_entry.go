@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"strconv"
+	"time"
+)
+
+type LedgerEntryTypeEnum string
+
+const (
+	LedgerEntryOpening    LedgerEntryTypeEnum = "OPENING"
+	LedgerEntryTopUp      LedgerEntryTypeEnum = "TOPUP"
+	LedgerEntryDebit      LedgerEntryTypeEnum = "DEBIT"
+	LedgerEntryRefund     LedgerEntryTypeEnum = "REFUND"
+	LedgerEntryAdjustment LedgerEntryTypeEnum = "ADJUSTMENT"
+)
+
+// System accounts on the other side of user credit movements.
+const (
+	LedgerAccountOpening     = "system:opening"
+	LedgerAccountFunding     = "system:funding"
+	LedgerAccountRevenue     = "system:revenue"
+	LedgerAccountAdjustments = "system:adjustments"
+)
+
+// Ledger reference types, telling what ReferenceID points at.
+const (
+	LedgerReferenceSMS         = "sms"
+	LedgerReferenceTransaction = "transaction"
+	LedgerReferenceAdmin       = "admin"
+)
+
+// LedgerEntry is one immutable leg of a credit movement. Every movement is a
+// journal of two entries with opposite amounts, one on the user's account and
+// one on a system account, so the entries of a journal always sum to zero.
+// Amounts are integer credit units; positive amounts credit the account.
+type LedgerEntry struct {
+	ID        uint64              `json:"id"`
+	JournalID string              `json:"journal_id" gorm:"not null"`
+	Account   string              `json:"account" gorm:"not null"`
+	UserID    *uint64             `json:"user_id,omitempty"` // set on user accounts only
+	Type      LedgerEntryTypeEnum `json:"type" gorm:"not null"`
+	Amount    int64               `json:"amount"`
+	// BalanceAfter is the user's balance right after this entry. System
+	// accounts don't keep a running balance.
+	BalanceAfter  *int64    `json:"balance_after,omitempty"`
+	ReferenceType string    `json:"reference_type"`
+	ReferenceID   uint64    `json:"reference_id"`
+	Description   string    `json:"description,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func UserLedgerAccount(userID uint64) string {
+	return "user:" + strconv.FormatUint(userID, 10)
+}
+
+// LedgerContraAccount is the system account balancing entries of type t.
+func LedgerContraAccount(t LedgerEntryTypeEnum) string {
+	switch t {
+	case LedgerEntryOpening:
+		return LedgerAccountOpening
+	case LedgerEntryTopUp:
+		return LedgerAccountFunding
+	case LedgerEntryDebit, LedgerEntryRefund:
+		return LedgerAccountRevenue
+	default:
+		return LedgerAccountAdjustments
+	}
+}
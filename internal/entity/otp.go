@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+type OTP struct {
+	ID            uint64     `json:"id"`
+	UserID        uint64     `json:"user_id"`
+	ReceiveNumber string     `json:"receive_number"`
+	CodeHash      string     `json:"-"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	SMSID         *uint64    `json:"sms_id"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (o *OTP) IsExpired(now time.Time) bool {
+	return !now.Before(o.ExpiresAt)
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// OutboxMessage is an SMS waiting to be published to the broker. It's written
+// in the same transaction that charges for the SMS, and the relay publishes it
+// from there, so a paid message always reaches the queue eventually.
+type OutboxMessage struct {
+	ID           uint64          `json:"id"`
+	SMSID        uint64          `json:"sms_id" gorm:"not null"`
+	UserID       uint64          `json:"user_id" gorm:"not null"`
+	Priority     SMSPriorityEnum `json:"priority" gorm:"not null;default:NORMAL"`
+	ExpiresAt    *time.Time      `json:"expires_at,omitempty"` // copied from the SMS
+	Attempts     int             `json:"attempts"`             // failed publishes so far
+	LastError    string          `json:"last_error,omitempty"`
+	AvailableAt  time.Time       `json:"available_at"` // not published before this
+	DispatchedAt *time.Time      `json:"dispatched_at,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+type ReconciliationFindingKindEnum string
+
+const (
+	// A debit transaction that isn't linked to any SMS.
+	FindingOrphanedTransaction ReconciliationFindingKindEnum = "ORPHANED_TRANSACTION"
+	// A charged SMS without a debit transaction.
+	FindingMissingTransaction ReconciliationFindingKindEnum = "MISSING_TRANSACTION"
+	// An SMS and its debit transaction disagree on the outcome.
+	FindingStatusMismatch ReconciliationFindingKindEnum = "STATUS_MISMATCH"
+	// A failed, cancelled or expired SMS whose cost was never refunded.
+	FindingMissingRefund ReconciliationFindingKindEnum = "MISSING_REFUND"
+	// A user's credit doesn't match the sum of their ledger entries.
+	FindingBalanceDrift ReconciliationFindingKindEnum = "BALANCE_DRIFT"
+)
+
+type ReconciliationTriggerEnum string
+
+const (
+	ReconciliationTriggerSchedule ReconciliationTriggerEnum = "SCHEDULE"
+	ReconciliationTriggerAdmin    ReconciliationTriggerEnum = "ADMIN"
+	ReconciliationTriggerCLI      ReconciliationTriggerEnum = "CLI"
+)
+
+// ReconciliationReport is the outcome of one cross-check of messages,
+// transactions and balances.
+type ReconciliationReport struct {
+	ID          uint64                    `json:"id"`
+	TriggeredBy ReconciliationTriggerEnum `json:"triggered_by" gorm:"not null"`
+	Repair      bool                      `json:"repair"` // whether safe cases were repaired
+	Issues      int                       `json:"issues"`
+	Repaired    int                       `json:"repaired"`
+	StartedAt   time.Time                 `json:"started_at"`
+	FinishedAt  time.Time                 `json:"finished_at"`
+	Findings    []ReconciliationFinding   `json:"findings,omitempty" gorm:"foreignKey:ReportID"`
+}
+
+type ReconciliationFinding struct {
+	ID            uint64                        `json:"id"`
+	ReportID      uint64                        `json:"report_id" gorm:"not null"`
+	Kind          ReconciliationFindingKindEnum `json:"kind" gorm:"not null"`
+	UserID        *uint64                       `json:"user_id,omitempty"`
+	SMSID         *uint64                       `json:"sms_id,omitempty"`
+	TransactionID *uint64                       `json:"transaction_id,omitempty"`
+	Detail        string                        `json:"detail"`
+	Repaired      bool                          `json:"repaired"`
+}
@@ -5,18 +5,41 @@ import "time"
 type SMSStatusEnum string
 
 const (
-	SMSStatusPending SMSStatusEnum = "PENDING"
-	SMSStatusSent    SMSStatusEnum = "SENT"
-	SMSStatusFailed  SMSStatusEnum = "FAILED"
+	SMSStatusPending   SMSStatusEnum = "PENDING"
+	SMSStatusSending   SMSStatusEnum = "SENDING" // claimed by a worker that is handing it to the provider
+	SMSStatusSent      SMSStatusEnum = "SENT"
+	SMSStatusFailed    SMSStatusEnum = "FAILED"
+	SMSStatusCancelled SMSStatusEnum = "CANCELLED"
+	SMSStatusExpired   SMSStatusEnum = "EXPIRED"
+)
+
+type SMSPriorityEnum string
+
+const (
+	SMSPriorityLow    SMSPriorityEnum = "LOW" // bulk traffic such as marketing
+	SMSPriorityNormal SMSPriorityEnum = "NORMAL"
+	SMSPriorityHigh   SMSPriorityEnum = "HIGH" // OTPs and other transactional traffic
 )
 
 type SMS struct {
-	ID            uint64        `json:"id"`
-	UserID        uint64        `json:"user_id"`
-	ReceiveNumber string        `json:"receive_number"`
-	Message       string        `json:"message"`
-	Status        SMSStatusEnum `json:"status"`
-	Cost          uint32        `json:"cost"`
-	CreatedAt     time.Time     `json:"created_at"`
-	UpdatedAt     time.Time     `json:"updated_at"`
+	ID            uint64          `json:"id"`
+	UserID        uint64          `json:"user_id"`
+	APIKeyID      *uint64         `json:"api_key_id,omitempty"` // the key the message was sent with, if any
+	BatchID       string          `json:"batch_id,omitempty"`
+	ReceiveNumber string          `json:"receive_number"`
+	Message       string          `json:"message"`
+	Status        SMSStatusEnum   `json:"status"`
+	Priority      SMSPriorityEnum `json:"priority" gorm:"not null;default:NORMAL"`
+	Cost          uint32          `json:"cost"`
+	ExpiresAt     *time.Time      `json:"expires_at,omitempty"` // nil when the message never expires
+	FailureReason string          `json:"failure_reason,omitempty"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"` // error of the last failed attempt
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// IsExpired reports whether the message's validity period has passed.
+func (s *SMS) IsExpired(now time.Time) bool {
+	return s.ExpiresAt != nil && !now.Before(*s.ExpiresAt)
 }
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// SMSStatusEvent records a single status transition of an SMS. The ID is used
+// as the SSE event id, so clients can resume a stream with Last-Event-ID.
+type SMSStatusEvent struct {
+	ID        uint64        `json:"id"`
+	SMSID     uint64        `json:"sms_id"`
+	UserID    uint64        `json:"user_id"`
+	BatchID   string        `json:"batch_id,omitempty"`
+	Status    SMSStatusEnum `json:"status"`
+	CreatedAt time.Time     `json:"created_at"`
+}
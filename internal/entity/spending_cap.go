@@ -0,0 +1,64 @@
+package entity
+
+import "time"
+
+type SpendingCapPeriodEnum string
+
+const (
+	SpendingCapDaily   SpendingCapPeriodEnum = "DAILY"
+	SpendingCapMonthly SpendingCapPeriodEnum = "MONTHLY"
+)
+
+// Start returns the start of the calendar period containing t, in t's
+// location.
+func (p SpendingCapPeriodEnum) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	if p == SpendingCapMonthly {
+		day = 1
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// End returns the start of the period after the one starting at start.
+func (p SpendingCapPeriodEnum) End(start time.Time) time.Time {
+	if p == SpendingCapMonthly {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Key identifies the period starting at start, e.g. "2026-01-31" for a day
+// or "2026-01" for a month.
+func (p SpendingCapPeriodEnum) Key(start time.Time) string {
+	if p == SpendingCapMonthly {
+		return start.Format("2006-01")
+	}
+	return start.Format("2006-01-02")
+}
+
+// SpendingCap limits what a user, or one of their API keys, can spend or send
+// per calendar day or month in the user's timezone.
+type SpendingCap struct {
+	ID          uint64                `json:"id"`
+	UserID      uint64                `json:"user_id" gorm:"not null;uniqueIndex:idx_spending_caps_scope"`
+	APIKeyID    uint64                `json:"api_key_id,omitempty" gorm:"not null;default:0;uniqueIndex:idx_spending_caps_scope"` // 0 for caps on the whole account
+	Period      SpendingCapPeriodEnum `json:"period" gorm:"not null;uniqueIndex:idx_spending_caps_scope"`
+	MaxAmount   *int64                `json:"max_amount,omitempty"`
+	MaxMessages *int64                `json:"max_messages,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// SpendingUsage is what was spent within one period of a cap.
+type SpendingUsage struct {
+	UserID    uint64                `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	APIKeyID  uint64                `json:"api_key_id" gorm:"primaryKey;autoIncrement:false"`
+	Period    SpendingCapPeriodEnum `json:"period" gorm:"primaryKey"`
+	PeriodKey string                `json:"period_key" gorm:"primaryKey"`
+	Amount    int64                 `json:"amount"`
+	Messages  int64                 `json:"messages"`
+}
+
+func (SpendingUsage) TableName() string {
+	return "spending_usage"
+}
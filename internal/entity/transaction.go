@@ -21,7 +21,7 @@ type Transaction struct {
 	ID        uint64                `json:"id"`
 	UserID    uint64                `json:"user_id"`
 	SMSID     *uint64               `json:"sms_id"` // Optional, for SMS-related transactions
-	Amount    float64               `json:"amount"`
+	Amount    int64                 `json:"amount"` // credit units
 	Operation OperationEnum         `json:"operation"`
 	Status    TransactionStatusEnum `json:"status"`
 	CreatedAt time.Time             `json:"created_at"`
@@ -2,11 +2,65 @@ package entity
 
 import "time"
 
+type UserStatusEnum string
+
+const (
+	UserStatusActive    UserStatusEnum = "ACTIVE"
+	UserStatusSuspended UserStatusEnum = "SUSPENDED"
+)
+
+type AccountTypeEnum string
+
+const (
+	AccountTypePrepaid  AccountTypeEnum = "PREPAID"
+	AccountTypePostpaid AccountTypeEnum = "POSTPAID"
+)
+
 type User struct {
-	ID          uint32    `json:"id"`
-	Name        string    `json:"name" gorm:"not null"`
-	PhoneNumber string    `json:"phone_number" gorm:"uniqueIndex;not null"`
-	Credit      int64     `json:"credit"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID               uint32          `json:"id"`
+	Name             string          `json:"name" gorm:"not null"`
+	PhoneNumber      string          `json:"phone_number" gorm:"uniqueIndex;not null"`
+	Credit           int64           `json:"credit"`
+	AccountType      AccountTypeEnum `json:"account_type" gorm:"not null;default:PREPAID"`
+	CreditLimit      int64           `json:"credit_limit"` // how far below zero a postpaid account may go
+	Plan             string          `json:"plan" gorm:"not null;default:basic"`
+	RateLimit        *int            `json:"rate_limit"` // overrides the plan's limit across all endpoints
+	Status           UserStatusEnum  `json:"status" gorm:"not null;default:ACTIVE"`
+	SuspensionReason string          `json:"suspension_reason,omitempty"`
+	SuspendedAt      *time.Time      `json:"suspended_at,omitempty"`
+	Timezone         string          `json:"timezone" gorm:"not null;default:UTC"` // spending caps reset at midnight here
+	WebhookURL       string          `json:"webhook_url,omitempty"`
+	WebhookSecret    string          `json:"-"`
+	APIKey           string          `json:"api_key,omitempty" gorm:"-"` // only set right after creation
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+func (u *User) IsPostpaid() bool {
+	return u.AccountType == AccountTypePostpaid
+}
+
+// MinimumBalance is the lowest credit a debit may leave the user with.
+func (u *User) MinimumBalance() int64 {
+	if u.IsPostpaid() {
+		return -u.CreditLimit
+	}
+	return 0
+}
+
+// Location returns the user's timezone, falling back to UTC when it's unset
+// or unknown.
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
 }
@@ -2,19 +2,13 @@ package errors
 
 import (
 	"errors"
+	"net/http"
 	"strings"
 
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 )
 
-// Custom error types
-var (
-	ErrUserAlreadyExists = errors.New("user with this phone number already exists")
-	ErrUserNotFound      = errors.New("user not found")
-	ErrInvalidInput      = errors.New("invalid input data")
-)
-
 // Business logic error types
 type BusinessError struct {
 	Code    string `json:"code"`
@@ -27,12 +21,78 @@ func (e BusinessError) Error() string {
 
 // Error codes
 const (
-	CodeUserAlreadyExists = "USER_ALREADY_EXISTS"
-	CodeUserNotFound      = "USER_NOT_FOUND"
-	CodeInvalidInput      = "INVALID_INPUT"
-	CodeInternalError     = "INTERNAL_ERROR"
+	CodeUserAlreadyExists   = "USER_ALREADY_EXISTS"
+	CodeUserNotFound        = "USER_NOT_FOUND"
+	CodeUserSuspended       = "USER_SUSPENDED"
+	CodeInvalidInput        = "INVALID_INPUT"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeNotFound            = "NOT_FOUND"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeInsufficientCredit  = "INSUFFICIENT_CREDIT"
+	CodeSMSNotFound         = "SMS_NOT_FOUND"
+	CodeSMSNotCancellable   = "SMS_NOT_CANCELLABLE"
+	CodeRecipientSuppressed = "RECIPIENT_SUPPRESSED"
+	CodeQueueUnavailable    = "QUEUE_UNAVAILABLE"
+	CodeOTPInvalid          = "OTP_INVALID"
+	CodeOTPExpired          = "OTP_EXPIRED"
+	CodeOTPAttemptsExceeded = "OTP_ATTEMPTS_EXCEEDED"
+	CodeOTPResendCooldown   = "OTP_RESEND_COOLDOWN"
+	CodeInboundRouteExists  = "INBOUND_ROUTE_EXISTS"
+	CodeSpendingCapExceeded = "SPENDING_CAP_EXCEEDED"
+	CodeInternalError       = "INTERNAL_ERROR"
+)
+
+// Custom error types
+var (
+	ErrUserAlreadyExists    = NewBusinessError(CodeUserAlreadyExists, "A user with this phone number already exists")
+	ErrUserNotFound         = NewBusinessError(CodeUserNotFound, "User not found")
+	ErrUserSuspended        = NewBusinessError(CodeUserSuspended, "User account is suspended")
+	ErrForbidden            = NewBusinessError(CodeForbidden, "You are not allowed to access this resource")
+	ErrInvalidInput         = NewBusinessError(CodeInvalidInput, "Invalid input data")
+	ErrUnauthorized         = NewBusinessError(CodeUnauthorized, "Invalid or missing API key")
+	ErrRateLimited          = NewBusinessError(CodeRateLimited, "Rate limit exceeded")
+	ErrSMSNotFound          = NewBusinessError(CodeSMSNotFound, "SMS not found")
+	ErrSMSNotCancellable    = NewBusinessError(CodeSMSNotCancellable, "Only pending SMS can be cancelled")
+	ErrInsufficientCredit   = NewBusinessError(CodeInsufficientCredit, "User does not have enough credit")
+	ErrRecipientSuppressed  = NewBusinessError(CodeRecipientSuppressed, "Recipient has opted out of receiving messages")
+	ErrQueueUnavailable     = NewBusinessError(CodeQueueUnavailable, "Message queue is unavailable, please retry later")
+	ErrOTPInvalid           = NewBusinessError(CodeOTPInvalid, "The verification code is invalid")
+	ErrOTPExpired           = NewBusinessError(CodeOTPExpired, "The verification code has expired or was already used")
+	ErrOTPAttemptsExceeded  = NewBusinessError(CodeOTPAttemptsExceeded, "Too many wrong attempts, request a new code")
+	ErrOTPResendCooldown    = NewBusinessError(CodeOTPResendCooldown, "A code was sent recently, please wait before requesting another")
+	ErrInboundRouteExists   = NewBusinessError(CodeInboundRouteExists, "A route for this number and keyword already exists")
+	ErrInboundRouteNotFound = NewBusinessError(CodeNotFound, "Inbound route not found")
+	ErrReportNotFound       = NewBusinessError(CodeNotFound, "Reconciliation report not found")
+	ErrSpendingCapExceeded  = NewBusinessError(CodeSpendingCapExceeded, "Sending this message would exceed a spending cap")
+	ErrAPIKeyNotFound       = NewBusinessError(CodeNotFound, "API key not found")
+	ErrDeadLetterNotFound   = NewBusinessError(CodeNotFound, "Dead-lettered message not found")
 )
 
+// HTTPStatus maps a business error code to its HTTP status code
+func HTTPStatus(code string) int {
+	switch code {
+	case CodeInvalidInput, CodeOTPInvalid, CodeOTPExpired:
+		return http.StatusBadRequest
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeInsufficientCredit:
+		return http.StatusPaymentRequired
+	case CodeForbidden, CodeUserSuspended, CodeRecipientSuppressed:
+		return http.StatusForbidden
+	case CodeNotFound, CodeUserNotFound, CodeSMSNotFound:
+		return http.StatusNotFound
+	case CodeUserAlreadyExists, CodeSMSNotCancellable, CodeInboundRouteExists:
+		return http.StatusConflict
+	case CodeRateLimited, CodeOTPAttemptsExceeded, CodeOTPResendCooldown, CodeSpendingCapExceeded:
+		return http.StatusTooManyRequests
+	case CodeQueueUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // NewBusinessError creates a new business error
 func NewBusinessError(code, message string) *BusinessError {
 	return &BusinessError{
@@ -43,13 +103,19 @@ func NewBusinessError(code, message string) *BusinessError {
 
 // ParseDatabaseError parses database errors and returns appropriate business errors
 func ParseDatabaseError(err error) error {
+	return ParseDatabaseErrorFor(err, ErrUserNotFound)
+}
+
+// ParseDatabaseErrorFor is like ParseDatabaseError, but reports a missing
+// record as notFound
+func ParseDatabaseErrorFor(err error, notFound *BusinessError) error {
 	if err == nil {
 		return nil
 	}
 
 	// Handle GORM specific errors
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return NewBusinessError(CodeUserNotFound, "User not found")
+		return notFound
 	}
 
 	// Handle MySQL specific errors
@@ -76,6 +142,10 @@ func parseDuplicateKeyError(mysqlErr *mysql.MySQLError) error {
 		return NewBusinessError(CodeUserAlreadyExists, "A user with this phone number already exists")
 	}
 
+	if strings.Contains(message, "idx_inbound_routes_number_keyword") {
+		return ErrInboundRouteExists
+	}
+
 	// Generic duplicate key error
 	return NewBusinessError(CodeUserAlreadyExists, "A user with this information already exists")
 }
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type AdminDeadLetterHandler struct {
+	deadLetterService port.DeadLetterService
+	logger            *logger.Logger
+}
+
+func NewAdminDeadLetterHandler(deadLetterService port.DeadLetterService, logger *logger.Logger) *AdminDeadLetterHandler {
+	return &AdminDeadLetterHandler{
+		deadLetterService: deadLetterService,
+		logger:            logger,
+	}
+}
+
+type ListDeadLettersRequest struct {
+	Queue string `form:"queue"`
+	Limit int    `form:"limit" binding:"omitempty,max=1000"`
+}
+
+func (h *AdminDeadLetterHandler) List(c *gin.Context) {
+	var req ListDeadLettersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+
+	messages, err := h.deadLetterService.List(c, req.Queue, req.Limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"count":    len(messages),
+	})
+}
+
+// Replay moves the message in the :id route parameter back to its queue, or
+// every message when there is none.
+func (h *AdminDeadLetterHandler) Replay(c *gin.Context) {
+	queue := c.Query("queue")
+	replayed, err := h.deadLetterService.Replay(c, queue, c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "Dead-lettered messages replayed", "queue", queue, "message_id", c.Param("id"), "replayed", replayed)
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+// Purge drops the message in the :id route parameter, or every message when
+// there is none.
+func (h *AdminDeadLetterHandler) Purge(c *gin.Context) {
+	queue := c.Query("queue")
+	purged, err := h.deadLetterService.Purge(c, queue, c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "Dead-lettered messages purged", "queue", queue, "message_id", c.Param("id"), "purged", purged)
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type AdminInboundRouteHandler struct {
+	inboundService port.InboundService
+	logger         *logger.Logger
+}
+
+func NewAdminInboundRouteHandler(inboundService port.InboundService, logger *logger.Logger) *AdminInboundRouteHandler {
+	return &AdminInboundRouteHandler{
+		inboundService: inboundService,
+		logger:         logger,
+	}
+}
+
+type ListInboundRoutesRequest struct {
+	UserID uint64 `form:"user_id"`
+}
+
+func (h *AdminInboundRouteHandler) List(c *gin.Context) {
+	var req ListInboundRoutesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	routes, err := h.inboundService.ListRoutes(c, req.UserID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"routes": routes,
+	})
+}
+
+type CreateInboundRouteRequest struct {
+	UserID  uint64 `json:"user_id" binding:"required"`
+	Number  string `json:"number" binding:"required,max=20"`
+	Keyword string `json:"keyword" binding:"omitempty,max=32,alphanum"`
+}
+
+func (h *AdminInboundRouteHandler) Create(c *gin.Context) {
+	var req CreateInboundRouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	route := &entity.InboundRoute{
+		UserID:  req.UserID,
+		Number:  req.Number,
+		Keyword: req.Keyword,
+	}
+	if err := h.inboundService.CreateRoute(c, route); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "Inbound route created", "route_id", route.ID, "user_id", route.UserID, "number", route.Number, "keyword", route.Keyword)
+	c.JSON(http.StatusCreated, route)
+}
+
+func (h *AdminInboundRouteHandler) Delete(c *gin.Context) {
+	routeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, "Invalid route id"))
+		return
+	}
+
+	if err := h.inboundService.DeleteRoute(c, routeID); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "Inbound route deleted", "route_id", routeID)
+	c.Status(http.StatusNoContent)
+}
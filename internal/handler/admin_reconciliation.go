@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type AdminReconciliationHandler struct {
+	reconciliationService port.ReconciliationService
+	logger                *logger.Logger
+}
+
+func NewAdminReconciliationHandler(reconciliationService port.ReconciliationService, logger *logger.Logger) *AdminReconciliationHandler {
+	return &AdminReconciliationHandler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+	}
+}
+
+type ListReportsRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size" binding:"omitempty,max=100"`
+}
+
+func (h *AdminReconciliationHandler) List(c *gin.Context) {
+	var req ListReportsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	reports, total, err := h.reconciliationService.ListReports(c, req.Page, req.PageSize)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports":   reports,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+func (h *AdminReconciliationHandler) Get(c *gin.Context) {
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, "Invalid report id"))
+		return
+	}
+
+	report, err := h.reconciliationService.GetReport(c, reportID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+type RunReconciliationRequest struct {
+	Repair bool `form:"repair"`
+}
+
+func (h *AdminReconciliationHandler) Run(c *gin.Context) {
+	var req RunReconciliationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	report, err := h.reconciliationService.Reconcile(c, entity.ReconciliationTriggerAdmin, req.Repair)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "Reconciliation run", "report_id", report.ID, "issues", report.Issues, "repaired", report.Repaired)
+	c.JSON(http.StatusOK, report)
+}
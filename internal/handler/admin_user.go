@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type AdminUserHandler struct {
+	userService   port.UserService
+	ledgerService port.LedgerService
+	logger        *logger.Logger
+}
+
+func NewAdminUserHandler(userService port.UserService, ledgerService port.LedgerService, logger *logger.Logger) *AdminUserHandler {
+	return &AdminUserHandler{
+		userService:   userService,
+		ledgerService: ledgerService,
+		logger:        logger,
+	}
+}
+
+type ListUsersRequest struct {
+	Query    string                `form:"query"`
+	Status   entity.UserStatusEnum `form:"status" binding:"omitempty,oneof=ACTIVE SUSPENDED"`
+	Page     int                   `form:"page"`
+	PageSize int                   `form:"page_size" binding:"omitempty,max=100"`
+}
+
+func (h *AdminUserHandler) List(c *gin.Context) {
+	var req ListUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	users, total, err := h.userService.ListUsers(c, port.UserFilter{Query: req.Query, Status: req.Status}, req.Page, req.PageSize)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+func (h *AdminUserHandler) Get(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	details, err := h.userService.GetUserDetails(c, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+type UpdateProfileRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=255"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,min=11,max=11"`
+}
+
+func (h *AdminUserHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c, userID, port.UserProfileUpdate{
+		Name:        req.Name,
+		PhoneNumber: req.PhoneNumber,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+func (h *AdminUserHandler) Suspend(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	user, err := h.userService.SuspendUser(c, userID, req.Reason)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "User suspended", "user_id", userID, "reason", req.Reason)
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminUserHandler) Reactivate(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.userService.ReactivateUser(c, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "User reactivated", "user_id", userID)
+	c.JSON(http.StatusOK, user)
+}
+
+type UpdateLimitsRequest struct {
+	Plan      string `json:"plan" binding:"required,max=32"`
+	RateLimit *int   `json:"rate_limit" binding:"omitempty,min=1"`
+}
+
+func (h *AdminUserHandler) UpdateLimits(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	user, err := h.userService.UpdateLimits(c, userID, port.UserLimits{
+		Plan:      req.Plan,
+		RateLimit: req.RateLimit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+type UpdateBillingRequest struct {
+	AccountType entity.AccountTypeEnum `json:"account_type" binding:"required,oneof=PREPAID POSTPAID"`
+	CreditLimit int64                  `json:"credit_limit" binding:"min=0"`
+}
+
+func (h *AdminUserHandler) UpdateBilling(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateBillingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	user, err := h.userService.UpdateBilling(c, userID, port.UserBilling{
+		AccountType: req.AccountType,
+		CreditLimit: req.CreditLimit,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "User billing updated", "user_id", userID, "account_type", req.AccountType, "credit_limit", req.CreditLimit)
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminUserHandler) Outstanding(c *gin.Context) {
+	report, err := h.userService.OutstandingBalances(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+type ListLedgerRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size" binding:"omitempty,max=100"`
+}
+
+func (h *AdminUserHandler) Ledger(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req ListLedgerRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	entries, total, err := h.ledgerService.ListEntries(c, userID, req.Page, req.PageSize)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":   entries,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+func (h *AdminUserHandler) VerifyLedger(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	verification, err := h.ledgerService.Verify(c, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
+type AdjustCreditRequest struct {
+	Amount int64  `json:"amount" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+func (h *AdminUserHandler) AdjustCredit(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req AdjustCreditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	entry, err := h.ledgerService.AdjustCredit(c, userID, req.Amount, req.Reason)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.logger.Info(c, "User credit adjusted", "user_id", userID, "amount", req.Amount, "reason", req.Reason)
+	c.JSON(http.StatusOK, entry)
+}
+
+// userIDParam parses the :id route parameter, reporting an input error to the
+// client when it isn't a valid id.
+func userIDParam(c *gin.Context) (uint64, bool) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, "Invalid user id"))
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type BalanceAlertHandler struct {
+	balanceAlertService port.BalanceAlertService
+	logger              *logger.Logger
+}
+
+func NewBalanceAlertHandler(balanceAlertService port.BalanceAlertService, logger *logger.Logger) *BalanceAlertHandler {
+	return &BalanceAlertHandler{
+		balanceAlertService: balanceAlertService,
+		logger:              logger,
+	}
+}
+
+func (h *BalanceAlertHandler) GetSettings(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	setting, err := h.balanceAlertService.GetSettings(c, uint64(user.ID))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+type UpdateBalanceAlertRequest struct {
+	Threshold     int64 `json:"threshold" binding:"min=0"`
+	NotifySMS     bool  `json:"notify_sms"`
+	NotifyWebhook bool  `json:"notify_webhook"`
+	Enabled       bool  `json:"enabled"`
+}
+
+func (h *BalanceAlertHandler) UpdateSettings(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req UpdateBalanceAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	setting, err := h.balanceAlertService.UpdateSettings(c, &entity.BalanceAlertSetting{
+		UserID:        uint64(user.ID),
+		Threshold:     req.Threshold,
+		NotifySMS:     req.NotifySMS,
+		NotifyWebhook: req.NotifyWebhook,
+		Enabled:       req.Enabled,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setting)
+}
+
+type BalanceAlertHistoryRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size" binding:"omitempty,max=100"`
+}
+
+func (h *BalanceAlertHandler) GetHistory(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req BalanceAlertHistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	alerts, total, err := h.balanceAlertService.ListAlerts(c, uint64(user.ID), req.Page, req.PageSize)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":    alerts,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+)
+
+type HealthHandler struct {
+	healthService port.HealthService
+}
+
+func NewHealthHandler(healthService port.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, h.healthService.Liveness(c))
+}
+
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.healthService.Readiness(c)
+	if report.Status != port.HealthStatusOK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
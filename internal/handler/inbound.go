@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type InboundHandler struct {
+	inboundService port.InboundService
+	logger         *logger.Logger
+}
+
+func NewInboundHandler(inboundService port.InboundService, logger *logger.Logger) *InboundHandler {
+	return &InboundHandler{
+		inboundService: inboundService,
+		logger:         logger,
+	}
+}
+
+type InboundCallbackRequest struct {
+	Provider   string     `json:"provider" binding:"required,max=64"`
+	MessageID  string     `json:"message_id" binding:"required,max=128"`
+	From       string     `json:"from" binding:"required,max=20"`
+	To         string     `json:"to" binding:"required,max=20"`
+	Message    string     `json:"message" binding:"required"`
+	ReceivedAt *time.Time `json:"received_at"`
+}
+
+// Callback accepts a mobile-originated message pushed by a provider.
+func (h *InboundHandler) Callback(c *gin.Context) {
+	var req InboundCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	input := port.InboundMessageInput{
+		Provider:          req.Provider,
+		ProviderMessageID: req.MessageID,
+		FromNumber:        req.From,
+		ToNumber:          req.To,
+		Message:           req.Message,
+	}
+	if req.ReceivedAt != nil {
+		input.ReceivedAt = *req.ReceivedAt
+	}
+
+	message, err := h.inboundService.Receive(c, input)
+	if err != nil {
+		h.logger.Error(c, "failed to receive inbound message", "error", err)
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id": message.ID,
+	})
+}
+
+type InboundHistoryRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size" binding:"omitempty,max=100"`
+}
+
+func (h *InboundHandler) GetHistory(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req InboundHistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	messages, err := h.inboundService.GetUserHistory(c, uint64(user.ID), req.Page, req.PageSize)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages":  messages,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
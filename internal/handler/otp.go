@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type OTPHandler struct {
+	otpService port.OTPService
+	logger     *logger.Logger
+}
+
+func NewOTPHandler(otpService port.OTPService, logger *logger.Logger) *OTPHandler {
+	return &OTPHandler{
+		otpService: otpService,
+		logger:     logger,
+	}
+}
+
+type SendOTPRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
+
+func (h *OTPHandler) Send(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req SendOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	var apiKeyID *uint64
+	if apiKey, ok := middleware.APIKeyFromContext(c); ok {
+		apiKeyID = &apiKey.ID
+	}
+
+	otp, err := h.otpService.SendOTP(c, uint64(user.ID), apiKeyID, req.PhoneNumber)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "otp sent",
+		"sms_id":     otp.SMSID,
+		"expires_at": otp.ExpiresAt,
+	})
+}
+
+type VerifyOTPRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+	Code        string `json:"code" binding:"required,numeric"`
+}
+
+func (h *OTPHandler) Verify(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req VerifyOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if err := h.otpService.VerifyOTP(c, uint64(user.ID), req.PhoneNumber, req.Code); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "otp verified",
+		"verified": true,
+	})
+}
@@ -2,8 +2,13 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
@@ -25,12 +30,18 @@ type SendSMSRequest struct {
 	ReceiveNumber string `json:"phone_number" binding:"required"`
 	Message       string `json:"message" binding:"required"`
 	UserID        uint64 `json:"user_id" binding:"required"`
+	BatchID       string `json:"batch_id" binding:"max=64"`
+	// ValidityPeriod is how many seconds the message is worth delivering.
+	ValidityPeriod int `json:"validity_period" binding:"omitempty,min=1,max=604800"`
+	// Priority picks the delivery lane: HIGH for OTPs and other transactional
+	// messages, LOW for bulk campaigns. Defaults to NORMAL.
+	Priority entity.SMSPriorityEnum `json:"priority" binding:"omitempty,oneof=HIGH NORMAL LOW"`
 }
 
 func (h *SMSHandler) Send(c *gin.Context) {
 	var req SendSMSRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
 		return
 	}
 
@@ -38,13 +49,22 @@ func (h *SMSHandler) Send(c *gin.Context) {
 		ReceiveNumber: req.ReceiveNumber,
 		Message:       req.Message,
 		UserID:        req.UserID,
+		BatchID:       req.BatchID,
 		Status:        entity.SMSStatusPending,
+		Priority:      req.Priority,
+	}
+	if apiKey, ok := middleware.APIKeyFromContext(c); ok && apiKey.UserID == req.UserID {
+		sms.APIKeyID = &apiKey.ID
+	}
+	if req.ValidityPeriod > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ValidityPeriod) * time.Second)
+		sms.ExpiresAt = &expiresAt
 	}
 
 	err := h.smsService.SendSMS(c, sms)
 	if err != nil {
 		h.logger.Error(c, "failed to send sms", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -63,7 +83,7 @@ type SMSHistoryRequest struct {
 func (h *SMSHandler) GetHistory(c *gin.Context) {
 	var req SMSHistoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
 		return
 	}
 
@@ -77,9 +97,31 @@ func (h *SMSHandler) GetHistory(c *gin.Context) {
 
 	history, err := h.smsService.GetUserHistory(c, req.UserID, req.Page, req.PageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, history)
 }
+
+func (h *SMSHandler) Cancel(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	smsID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, "Invalid sms id"))
+		return
+	}
+
+	sms, err := h.smsService.CancelSMS(c, smsID, uint64(user.ID))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sms)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type SpendingCapHandler struct {
+	spendingCapService port.SpendingCapService
+	logger             *logger.Logger
+}
+
+func NewSpendingCapHandler(spendingCapService port.SpendingCapService, logger *logger.Logger) *SpendingCapHandler {
+	return &SpendingCapHandler{
+		spendingCapService: spendingCapService,
+		logger:             logger,
+	}
+}
+
+// List returns the caller's caps with what was used in their current period.
+func (h *SpendingCapHandler) List(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	caps, err := h.spendingCapService.ListCaps(c, uint64(user.ID))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"timezone": user.Location().String(),
+		"caps":     caps,
+	})
+}
+
+type SetSpendingCapRequest struct {
+	APIKeyID    uint64                       `json:"api_key_id"`
+	Period      entity.SpendingCapPeriodEnum `json:"period" binding:"required,oneof=DAILY MONTHLY"`
+	MaxAmount   *int64                       `json:"max_amount" binding:"omitempty,min=0"`
+	MaxMessages *int64                       `json:"max_messages" binding:"omitempty,min=0"`
+}
+
+// Set creates or replaces one of the caller's caps. Leaving out both limits
+// removes the cap.
+func (h *SpendingCapHandler) Set(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req SetSpendingCapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	err := h.spendingCapService.SetCap(c, &entity.SpendingCap{
+		UserID:      uint64(user.ID),
+		APIKeyID:    req.APIKeyID,
+		Period:      req.Period,
+		MaxAmount:   req.MaxAmount,
+		MaxMessages: req.MaxMessages,
+	})
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	h.List(c)
+}
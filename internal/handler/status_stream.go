@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+const (
+	statusStreamReplayPageSize = 500
+	statusStreamHeartbeat      = 15 * time.Second
+)
+
+type StatusStreamHandler struct {
+	statusStream port.SMSStatusStream
+	logger       *logger.Logger
+}
+
+func NewStatusStreamHandler(statusStream port.SMSStatusStream, logger *logger.Logger) *StatusStreamHandler {
+	return &StatusStreamHandler{
+		statusStream: statusStream,
+		logger:       logger,
+	}
+}
+
+// Stream sends the caller's SMS status transitions as server-sent events,
+// optionally limited to one batch. Missed events are replayed from the
+// Last-Event-ID header (or last_event_id query parameter) before going live.
+func (h *StatusStreamHandler) Stream(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var afterID uint64
+	if lastEventID != "" {
+		var err error
+		afterID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, "Invalid Last-Event-ID"))
+			return
+		}
+	}
+
+	filter := port.SMSStatusEventFilter{
+		UserID:  uint64(user.ID),
+		BatchID: c.Query("batch_id"),
+	}
+
+	// Subscribe before replaying so nothing committed in between is lost;
+	// live events already sent by the replay are skipped below.
+	subscription := h.statusStream.Subscribe(filter)
+	defer h.statusStream.Unsubscribe(subscription)
+
+	if afterID > 0 {
+		var err error
+		afterID, err = h.statusStream.ReplayStart(c, filter, afterID)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	replayed := make(map[uint64]struct{})
+	for {
+		events, err := h.statusStream.Replay(c, filter, afterID, statusStreamReplayPageSize)
+		if err != nil {
+			h.logger.Error(c, "failed to replay sms status events", "error", err.Error(), "user_id", filter.UserID)
+			return
+		}
+		for _, event := range events {
+			renderStatusEvent(c, event)
+			replayed[event.ID] = struct{}{}
+			afterID = event.ID
+		}
+		c.Writer.Flush()
+		if len(events) < statusStreamReplayPageSize {
+			break
+		}
+	}
+	heartbeat := time.NewTicker(statusStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// Live events that raced the replay have been delivered by now.
+			replayed = nil
+			c.SSEvent("ping", strconv.FormatInt(time.Now().Unix(), 10))
+			return true
+		case event, ok := <-subscription.Events:
+			if !ok {
+				return false
+			}
+			// Compare ids individually rather than against the last replayed
+			// one: a lower id can commit after a higher one.
+			if _, ok := replayed[event.ID]; !ok {
+				renderStatusEvent(c, event)
+			}
+			return true
+		}
+	})
+}
+
+func renderStatusEvent(c *gin.Context, event entity.SMSStatusEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: "status",
+		Data:  event,
+	})
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type TransactionHandler struct {
+	transactionService port.TransactionService
+	logger             *logger.Logger
+}
+
+func NewTransactionHandler(transactionService port.TransactionService, logger *logger.Logger) *TransactionHandler {
+	return &TransactionHandler{
+		transactionService: transactionService,
+		logger:             logger,
+	}
+}
+
+type TransactionFilterRequest struct {
+	Operation entity.OperationEnum         `form:"operation" binding:"omitempty,oneof=INCREASE DECREASE"`
+	Status    entity.TransactionStatusEnum `form:"status" binding:"omitempty,oneof=PENDING SUCCESS FAILED"`
+	From      time.Time                    `form:"from"`
+	To        time.Time                    `form:"to"`
+}
+
+func (r TransactionFilterRequest) filter(userID uint64) port.TransactionFilter {
+	return port.TransactionFilter{
+		UserID:    userID,
+		Operation: r.Operation,
+		Status:    r.Status,
+		From:      r.From,
+		To:        r.To,
+	}
+}
+
+type ListTransactionsRequest struct {
+	TransactionFilterRequest
+	Cursor uint64 `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,max=100"`
+}
+
+func (h *TransactionHandler) List(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req ListTransactionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	transactions, next, err := h.transactionService.ListTransactions(c, req.filter(uint64(user.ID)), req.Cursor, req.Limit)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	response := gin.H{
+		"transactions": transactions,
+		"next_cursor":  nil,
+	}
+	if next != 0 {
+		response["next_cursor"] = strconv.FormatUint(next, 10)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type ExportTransactionsRequest struct {
+	TransactionFilterRequest
+	Format string `form:"format" binding:"omitempty,oneof=csv json"`
+}
+
+var transactionCSVHeader = []string{"id", "created_at", "operation", "status", "amount", "sms_id"}
+
+// Export streams every matching transaction as CSV (the default) or as a JSON
+// array, without holding the whole history in memory.
+func (h *TransactionHandler) Export(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req ExportTransactionsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	filename := fmt.Sprintf("transactions-%d.%s", user.ID, req.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var err error
+	switch req.Format {
+	case "json":
+		c.Header("Content-Type", "application/json")
+		c.Status(http.StatusOK)
+		err = h.exportJSON(c, req.filter(uint64(user.ID)))
+	default:
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		err = h.exportCSV(c, req.filter(uint64(user.ID)))
+	}
+	if err != nil {
+		// Headers are already out, so the client only sees a truncated file.
+		h.logger.Error(c, "failed to export transactions", "error", err.Error(), "user_id", user.ID)
+	}
+}
+
+func (h *TransactionHandler) exportCSV(c *gin.Context, filter port.TransactionFilter) error {
+	writer := csv.NewWriter(c.Writer)
+	if err := writer.Write(transactionCSVHeader); err != nil {
+		return err
+	}
+
+	err := h.transactionService.ExportTransactions(c, filter, func(batch []entity.Transaction) error {
+		for _, transaction := range batch {
+			smsID := ""
+			if transaction.SMSID != nil {
+				smsID = strconv.FormatUint(*transaction.SMSID, 10)
+			}
+			record := []string{
+				strconv.FormatUint(transaction.ID, 10),
+				transaction.CreatedAt.Format(time.RFC3339),
+				string(transaction.Operation),
+				string(transaction.Status),
+				strconv.FormatInt(transaction.Amount, 10),
+				smsID,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		c.Writer.Flush()
+		return writer.Error()
+	})
+	writer.Flush()
+	return err
+}
+
+func (h *TransactionHandler) exportJSON(c *gin.Context, filter port.TransactionFilter) error {
+	if _, err := c.Writer.WriteString("["); err != nil {
+		return err
+	}
+
+	first := true
+	err := h.transactionService.ExportTransactions(c, filter, func(batch []entity.Transaction) error {
+		for _, transaction := range batch {
+			data, err := json.Marshal(transaction)
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := c.Writer.WriteString(","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := c.Writer.Write(data); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Writer.WriteString("]")
+	return err
+}
+
+type StatementRequest struct {
+	Month string `form:"month" binding:"omitempty,datetime=2006-01"`
+}
+
+func (h *TransactionHandler) Statement(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req StatementRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	month := time.Now().UTC()
+	if req.Month != "" {
+		month, _ = time.Parse("2006-01", req.Month)
+	}
+
+	statement, err := h.transactionService.MonthlyStatement(c, uint64(user.ID), month)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/middleware"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
@@ -30,7 +31,7 @@ type CreateUserRequest struct {
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
 		return
 	}
 
@@ -41,20 +42,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	user, err := h.userService.CreateUser(c, user)
 	if err != nil {
-		if businessErr, isBusiness := errors.IsBusinessError(err); isBusiness {
-			statusCode := getHTTPStatusFromErrorCode(businessErr.Code)
-			c.JSON(statusCode, gin.H{
-				"error": businessErr.Message,
-				"code":  businessErr.Code,
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Internal server error",
-			"code":  errors.CodeInternalError,
-		})
-		h.logger.Error(c, "Failed to create user", "error", err.Error())
+		_ = c.Error(err)
 		return
 	}
 
@@ -70,39 +58,74 @@ type UpdateCreditRequest struct {
 func (h *UserHandler) UpdateCredit(c *gin.Context) {
 	var req UpdateCreditRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
 		return
 	}
 
 	user, err := h.userService.UpdateCredit(c, req.UserID, req.Amount)
 	if err != nil {
-		// Check if it's a business error
-		if businessErr, isBusiness := errors.IsBusinessError(err); isBusiness {
-			statusCode := getHTTPStatusFromErrorCode(businessErr.Code)
-			c.JSON(statusCode, gin.H{
-				"error": businessErr.Message,
-				"code":  businessErr.Code,
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
 
-// getHTTPStatusFromErrorCode maps business error codes to HTTP status codes
-func getHTTPStatusFromErrorCode(code string) int {
-	switch code {
-	case errors.CodeUserAlreadyExists:
-		return http.StatusConflict
-	case errors.CodeUserNotFound:
-		return http.StatusNotFound
-	case errors.CodeInvalidInput:
-		return http.StatusBadRequest
-	default:
-		return http.StatusInternalServerError
+type SetWebhookRequest struct {
+	URL string `json:"url" binding:"omitempty,url,max=2048"`
+}
+
+// SetWebhook sets the caller's webhook URL and returns a new signing secret,
+// which is only shown once. An empty URL removes the webhook.
+func (h *UserHandler) SetWebhook(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req SetWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
+	}
+
+	user, secret, err := h.userService.SetWebhook(c, uint64(user.ID), req.URL)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook_url":    user.WebhookURL,
+		"webhook_secret": secret,
+	})
+}
+
+type SetTimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required,max=64"`
+}
+
+// SetTimezone sets the IANA timezone, e.g. "Asia/Tehran", the caller's
+// daily and monthly spending caps reset in.
+func (h *UserHandler) SetTimezone(c *gin.Context) {
+	user, ok := middleware.UserFromContext(c)
+	if !ok {
+		_ = c.Error(errors.ErrUnauthorized)
+		return
+	}
+
+	var req SetTimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(errors.NewBusinessError(errors.CodeInvalidInput, err.Error()))
+		return
 	}
+
+	user, err := h.userService.SetTimezone(c, uint64(user.ID), req.Timezone)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
 }
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+)
+
+// AdminOnly lets through requests whose X-Admin-Key header matches the
+// configured admin key. Everything is rejected when no admin key is set.
+func AdminOnly(config config.AdminConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Admin-Key")
+		if config.APIKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(config.APIKey)) != 1 {
+			_ = c.Error(errors.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+// ErrorHandler renders the last error attached with c.Error as the API's
+// error shape: {"error": message, "code": code}. Business errors get the
+// status of their code, anything else a generic 500.
+func ErrorHandler(logger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		ginErr := c.Errors.Last()
+		if businessErr, isBusiness := errors.IsBusinessError(ginErr.Err); isBusiness {
+			c.JSON(errors.HTTPStatus(businessErr.Code), gin.H{
+				"error": businessErr.Message,
+				"code":  businessErr.Code,
+			})
+			return
+		}
+
+		logger.Error(c, "Request failed", "path", c.FullPath(), "error", ginErr.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Internal server error",
+			"code":  errors.CodeInternalError,
+		})
+	}
+}
+
+// NotFound answers unknown routes in the API's error shape.
+func NotFound() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Route not found",
+			"code":  errors.CodeNotFound,
+		})
+	}
+}
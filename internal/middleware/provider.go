@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+)
+
+// ProviderCallback lets through provider callbacks whose X-Provider-Key header
+// matches the configured callback key. Everything is rejected when no callback
+// key is set.
+func ProviderCallback(config config.InboundConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Provider-Key")
+		if config.CallbackKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(config.CallbackKey)) != 1 {
+			_ = c.Error(errors.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/ratelimit"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+// allEndpoints is the rule name that limits every request of a caller.
+const allEndpoints = "*"
+
+type RateLimit struct {
+	limiter ratelimit.Limiter
+	config  config.RateLimitConfig
+	logger  *logger.Logger
+}
+
+func NewRateLimit(limiter ratelimit.Limiter, config config.RateLimitConfig, logger *logger.Logger) *RateLimit {
+	return &RateLimit{
+		limiter: limiter,
+		config:  config,
+		logger:  logger,
+	}
+}
+
+// Limit enforces the caller's plan limits for the named endpoint, on top of
+// the plan's limit across all endpoints (or the user's own override of it).
+// Authenticated callers are limited per user, anonymous ones per client IP on
+// the default plan.
+func (r *RateLimit) Limit(endpoint string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !r.config.Enabled {
+			c.Next()
+			return
+		}
+
+		plan := r.config.DefaultPlan
+		subject := "ip:" + c.ClientIP()
+		var override *int
+		if user, ok := UserFromContext(c); ok {
+			subject = fmt.Sprintf("user:%d", user.ID)
+			override = user.RateLimit
+			if _, known := r.config.Plans[user.Plan]; known {
+				plan = user.Plan
+			}
+		}
+
+		var tightest *ratelimit.Result
+		for _, rule := range []string{allEndpoints, endpoint} {
+			limit, ok := r.config.Plans[plan][rule]
+			if rule == allEndpoints && override != nil {
+				limit, ok = *override, true
+			}
+			if !ok {
+				continue
+			}
+
+			key := fmt.Sprintf("ratelimit:%s:%s", subject, rule)
+			result, err := r.limiter.Allow(c, key, limit, r.config.Window)
+			if err != nil {
+				// Fail open: a limiter outage must not take the API down.
+				r.logger.Error(c, "Failed to check rate limit", "error", err.Error(), "key", key)
+				continue
+			}
+
+			if tightest == nil || !result.Allowed || result.Remaining < tightest.Remaining {
+				tightest = &result
+			}
+			if !result.Allowed {
+				break
+			}
+		}
+
+		if tightest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(tightest.ResetAfter.Seconds())))
+
+		if !tightest.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(tightest.RetryAfter.Seconds())))
+			_ = c.Error(errors.ErrRateLimited)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(seconds float64) int {
+	return int(math.Ceil(seconds))
+}
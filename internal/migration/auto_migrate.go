@@ -18,6 +18,19 @@ func AutoMigrateEntities(ctx context.Context, db *gorm.DB, logger *logger.Logger
 		&entity.User{},
 		&entity.SMS{},
 		&entity.Transaction{},
+		&entity.APIKey{},
+		&entity.SMSStatusEvent{},
+		&entity.OTP{},
+		&entity.InboundRoute{},
+		&entity.InboundMessage{},
+		&entity.LedgerEntry{},
+		&entity.BalanceAlertSetting{},
+		&entity.BalanceAlert{},
+		&entity.ReconciliationReport{},
+		&entity.ReconciliationFinding{},
+		&entity.SpendingCap{},
+		&entity.SpendingUsage{},
+		&entity.OutboxMessage{},
 	}
 
 	if err := db.AutoMigrate(entities...); err != nil {
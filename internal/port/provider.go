@@ -12,6 +12,10 @@ type SendResponse struct {
 }
 
 type Provider interface {
+	// Send returns an error when delivery failed for a reason worth retrying,
+	// such as throttling or an outage. A response that isn't ok means the
+	// provider rejected the message for good.
 	Send(ctx context.Context, sms *entity.SMS) (*SendResponse, error)
 	DeliveryReport(ctx context.Context, sms *entity.SMS) (any, error)
+	Ping(ctx context.Context) error
 }
\ No newline at end of file
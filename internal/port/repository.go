@@ -2,28 +2,250 @@ package port
 
 import (
 	"context"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 )
 
+// UnitOfWork runs repository calls atomically. Calls made with the context
+// handed to fn share one database transaction.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type SMSRepository interface {
 	Create(ctx context.Context, sms *entity.SMS) error
 	GetByID(ctx context.Context, id uint64) (*entity.SMS, error)
 	Update(ctx context.Context, sms *entity.SMS) error
 	UserHistory(ctx context.Context, userID uint64, limit int, offset int) ([]entity.SMS, error)
 	UpdateStatus(ctx context.Context, smsID uint64, status entity.SMSStatusEnum) error
+	ClosePending(ctx context.Context, smsID uint64, status entity.SMSStatusEnum, reason string) (bool, error)
+	// Claim moves a pending SMS, or with takeover one already in SENDING, to
+	// SENDING. It reports whether the SMS was claimed.
+	Claim(ctx context.Context, smsID uint64, takeover bool) (bool, error)
+	ReleaseClaim(ctx context.Context, smsID uint64) (bool, error)
+	MarkSent(ctx context.Context, smsID uint64) (bool, error)
+	RecordAttempt(ctx context.Context, smsID uint64, attempts int, lastError string) error
+	ListExpiredPending(ctx context.Context, before time.Time, limit int) ([]entity.SMS, error)
+	UsageByUser(ctx context.Context, userID uint64) (*UserUsage, error)
 }
 
 type TransactionRepository interface {
 	Create(ctx context.Context, transaction *entity.Transaction) error
 	GetBySMSID(ctx context.Context, smsID uint64) (*entity.Transaction, error)
 	UpdateStatusBySMSID(ctx context.Context, smsID uint64, status entity.TransactionStatusEnum) error
+	// TransitionBySMSID is UpdateStatusBySMSID for a debit still in the from
+	// status. It reports whether the debit was updated.
+	TransitionBySMSID(ctx context.Context, smsID uint64, from entity.TransactionStatusEnum, to entity.TransactionStatusEnum) (bool, error)
+	// List returns transactions matching filter, newest first, starting
+	// below the beforeID cursor (0 starts from the newest).
+	List(ctx context.Context, filter TransactionFilter, beforeID uint64, limit int) ([]entity.Transaction, error)
+}
+
+// TransactionFilter narrows a user's transactions. Zero values don't filter;
+// From is inclusive and To exclusive.
+type TransactionFilter struct {
+	UserID    uint64
+	Operation entity.OperationEnum
+	Status    entity.TransactionStatusEnum
+	From      time.Time
+	To        time.Time
 }
 
 type UserRepository interface {
 	GetByID(ctx context.Context, id uint64) (*entity.User, error)
 	Create(ctx context.Context, user *entity.User) (*entity.User, error)
-	HasEnoughCredit(ctx context.Context, userID uint64, amount uint32) (bool, error)
-	IncreaseCredit(ctx context.Context, user *entity.User, amount uint32) error
-	DecreaseCredit(ctx context.Context, user *entity.User, amount uint32) error
+	Search(ctx context.Context, filter UserFilter, limit int, offset int) ([]entity.User, int64, error)
+	Update(ctx context.Context, userID uint64, fields map[string]any) error
+	// UpdateBilling sets the account type and credit limit. A switch to
+	// prepaid only applies while the balance isn't negative. It reports
+	// whether a row was changed.
+	UpdateBilling(ctx context.Context, userID uint64, accountType entity.AccountTypeEnum, creditLimit int64) (bool, error)
+	// ListOutstanding returns postpaid users with a negative balance, the
+	// largest debt first.
+	ListOutstanding(ctx context.Context) ([]entity.User, error)
+}
+
+type UserFilter struct {
+	Query  string // matches name or phone number
+	Status entity.UserStatusEnum
+}
+
+type UserUsage struct {
+	TotalMessages     int64 `json:"total_messages"`
+	PendingMessages   int64 `json:"pending_messages"`
+	SentMessages      int64 `json:"sent_messages"`
+	FailedMessages    int64 `json:"failed_messages"`
+	CancelledMessages int64 `json:"cancelled_messages"`
+	ExpiredMessages   int64 `json:"expired_messages"`
+	TotalSpent        int64 `json:"total_spent"`
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, apiKey *entity.APIKey) error
+	GetActiveByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	GetByID(ctx context.Context, id uint64) (*entity.APIKey, error)
+}
+
+type SMSStatusEventRepository interface {
+	Create(ctx context.Context, event *entity.SMSStatusEvent) error
+	ListAfter(ctx context.Context, filter SMSStatusEventFilter, afterID uint64, limit int) ([]entity.SMSStatusEvent, error)
+	OverlapStart(ctx context.Context, filter SMSStatusEventFilter, afterID uint64, window time.Duration) (uint64, error)
+}
+
+type SMSStatusEventFilter struct {
+	UserID  uint64
+	BatchID string
+}
+
+type OTPRepository interface {
+	Create(ctx context.Context, otp *entity.OTP) error
+	GetLatest(ctx context.Context, userID uint64, receiveNumber string) (*entity.OTP, error)
+	SetSMSID(ctx context.Context, otpID uint64, smsID uint64) error
+	Expire(ctx context.Context, otpID uint64) error
+	IncrementAttempts(ctx context.Context, otpID uint64) (bool, error)
+	MarkVerified(ctx context.Context, otpID uint64) (bool, error)
+}
+
+type InboundRouteRepository interface {
+	Create(ctx context.Context, route *entity.InboundRoute) error
+	Delete(ctx context.Context, routeID uint64) (bool, error)
+	List(ctx context.Context, userID uint64) ([]entity.InboundRoute, error)
+	ListByNumber(ctx context.Context, number string) ([]entity.InboundRoute, error)
+}
+
+type InboundMessageRepository interface {
+	Create(ctx context.Context, message *entity.InboundMessage) error
+	GetByProviderMessageID(ctx context.Context, provider string, providerMessageID string) (*entity.InboundMessage, error)
+	GetUserHistory(ctx context.Context, userID uint64, limit int, offset int) ([]entity.InboundMessage, error)
+	ListDueForForward(ctx context.Context, limit int) ([]entity.InboundMessage, error)
+	ClaimForward(ctx context.Context, messageID uint64, until time.Time) (bool, error)
+	RecordForwardAttempt(ctx context.Context, messageID uint64, status entity.InboundForwardStatusEnum, forwardError string, nextForwardAt *time.Time) error
+}
+
+// LedgerPosting is a credit movement of one user. Amount is in credit units;
+// a negative amount debits the user.
+type LedgerPosting struct {
+	UserID        uint64
+	Type          entity.LedgerEntryTypeEnum
+	Amount        int64
+	ReferenceType string
+	ReferenceID   uint64
+	Description   string
+}
+
+type LedgerRepository interface {
+	// Post applies the posting to the user's credit and records it as a
+	// journal of two entries, returning the user's entry. It returns nil,
+	// without changing anything, when a debit isn't covered by the credit.
+	Post(ctx context.Context, posting LedgerPosting) (*entity.LedgerEntry, error)
+	ListByUser(ctx context.Context, userID uint64, limit int, offset int) ([]entity.LedgerEntry, int64, error)
+	Summarize(ctx context.Context, userID uint64) (*LedgerSummary, error)
+	// BalanceBefore returns the user's balance right before t.
+	BalanceBefore(ctx context.Context, userID uint64, t time.Time) (int64, error)
+	// TotalsByType sums the user's entries in [from, to) per entry type.
+	TotalsByType(ctx context.Context, userID uint64, from time.Time, to time.Time) (map[entity.LedgerEntryTypeEnum]LedgerTotal, error)
+}
+
+type LedgerTotal struct {
+	Entries int64 `json:"entries"`
+	Amount  int64 `json:"amount"`
+}
+
+// LedgerSummary aggregates the entries on a user's account.
+type LedgerSummary struct {
+	Entries          int64  `json:"entries"`
+	Sum              int64  `json:"sum"`
+	LastBalanceAfter *int64 `json:"last_balance_after"`
+}
+
+type BalanceAlertRepository interface {
+	GetSettings(ctx context.Context, userID uint64) (*entity.BalanceAlertSetting, error)
+	SaveSettings(ctx context.Context, setting *entity.BalanceAlertSetting) error
+	// Claim marks an alert as sent when the user's enabled threshold is above
+	// balance and no alert went out since notAlertedSince. It returns the
+	// settings on success and nil when no alert is due.
+	Claim(ctx context.Context, userID uint64, balance int64, notAlertedSince time.Time) (*entity.BalanceAlertSetting, error)
+	CreateAlert(ctx context.Context, alert *entity.BalanceAlert) error
+	ListAlerts(ctx context.Context, userID uint64, limit int, offset int) ([]entity.BalanceAlert, int64, error)
+}
+
+// SMSTransactionMismatch is an SMS whose debit transaction disagrees with it.
+type SMSTransactionMismatch struct {
+	SMSID             uint64
+	UserID            uint64
+	SMSStatus         entity.SMSStatusEnum
+	TransactionID     uint64
+	TransactionStatus entity.TransactionStatusEnum
+	Refunded          bool // whether the ledger holds a refund for the SMS
+}
+
+type BalanceDrift struct {
+	UserID        uint64
+	Credit        int64
+	LedgerBalance int64
+}
+
+type ReconciliationRepository interface {
+	// FindOrphanedTransactions returns debit transactions not linked to an SMS.
+	FindOrphanedTransactions(ctx context.Context, limit int) ([]entity.Transaction, error)
+	// FindUnbilledSMS returns charged messages created before the given time
+	// that have no debit transaction.
+	FindUnbilledSMS(ctx context.Context, before time.Time, limit int) ([]entity.SMS, error)
+	// FindStatusMismatches returns messages last updated before the given
+	// time whose debit transaction doesn't match their status.
+	FindStatusMismatches(ctx context.Context, before time.Time, limit int) ([]SMSTransactionMismatch, error)
+	FindBalanceDrift(ctx context.Context, limit int) ([]BalanceDrift, error)
+	// CreateReport stores the report along with its findings.
+	CreateReport(ctx context.Context, report *entity.ReconciliationReport) error
+	ListReports(ctx context.Context, limit int, offset int) ([]entity.ReconciliationReport, int64, error)
+	// GetReport returns the report with its findings.
+	GetReport(ctx context.Context, id uint64) (*entity.ReconciliationReport, error)
+}
+
+type SpendingCapRepository interface {
+	ListByUser(ctx context.Context, userID uint64) ([]entity.SpendingCap, error)
+	// ListApplicable returns the user's account-wide caps and those of the
+	// given API key.
+	ListApplicable(ctx context.Context, userID uint64, apiKeyID uint64) ([]entity.SpendingCap, error)
+	// Save creates the cap or replaces the limits of the existing cap with the
+	// same user, key and period.
+	Save(ctx context.Context, spendingCap *entity.SpendingCap) error
+	Delete(ctx context.Context, userID uint64, apiKeyID uint64, period entity.SpendingCapPeriodEnum) error
+	// GetUsage returns the usage of a cap in a period, empty when nothing was
+	// spent yet.
+	GetUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string) (*entity.SpendingUsage, error)
+	// AddUsage adds one message costing amount to the cap's usage in a period,
+	// unless that would exceed the cap. It reports whether it was added.
+	AddUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string, amount int64) (bool, error)
+	// RemoveUsage takes one message costing amount back off the cap's usage in
+	// a period, never going below zero.
+	RemoveUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string, amount int64) error
+}
+
+type OutboxRepository interface {
+	Create(ctx context.Context, message *entity.OutboxMessage) error
+	// LockDueByPriority locks up to limit undispatched messages of a priority
+	// that are due, oldest first, skipping those another transaction holds.
+	// It must run in a unit of work, which holds the locks until it ends.
+	LockDueByPriority(ctx context.Context, priority entity.SMSPriorityEnum, limit int) ([]entity.OutboxMessage, error)
+	// LockDueForUser is LockDueByPriority for the normal and low priority
+	// messages of one user.
+	LockDueForUser(ctx context.Context, userID uint64, limit int) ([]entity.OutboxMessage, error)
+	// ListDueTenants returns up to limit users from fromUserID on, in order of
+	// id, with normal or low priority messages due, along with their plan.
+	ListDueTenants(ctx context.Context, fromUserID uint64, limit int) ([]OutboxTenant, error)
+	MarkDispatched(ctx context.Context, ids []uint64) error
+	// Reschedule counts a failed publish and holds the message back until
+	// availableAt.
+	Reschedule(ctx context.Context, id uint64, availableAt time.Time, lastError string) error
+	// DeleteDispatched deletes up to limit messages dispatched before the
+	// given time and returns how many it deleted.
+	DeleteDispatched(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// OutboxTenant is a user with messages waiting in the outbox.
+type OutboxTenant struct {
+	UserID uint64
+	Plan   string
 }
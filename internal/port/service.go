@@ -2,6 +2,7 @@ package port
 
 import (
 	"context"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/connection"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
@@ -13,15 +14,104 @@ type SMSService interface {
 	CalculateCost(ctx context.Context, sms *entity.SMS) *entity.SMS
 	GetSMSByID(ctx context.Context, smsID uint64) (*entity.SMS, error)
 	UpdateSMSStatus(ctx context.Context, smsID uint64, status entity.SMSStatusEnum) error
+	CancelSMS(ctx context.Context, smsID uint64, userID uint64) (*entity.SMS, error)
+	ExpireSMS(ctx context.Context, smsID uint64) (bool, error)
+	FailSMS(ctx context.Context, smsID uint64, reason string) (bool, error)
+	// ClaimSMS moves a pending SMS to SENDING before it's handed to the
+	// provider, so it can't be cancelled or expired while it's being sent.
+	// With takeover it also claims an SMS left in SENDING by a worker that
+	// went away. It reports whether the SMS was claimed.
+	ClaimSMS(ctx context.Context, smsID uint64, takeover bool) (bool, error)
+	// ReleaseSMS moves a claimed SMS back to PENDING.
+	ReleaseSMS(ctx context.Context, smsID uint64) error
+	// CompleteSMS marks a claimed SMS as SENT and its debit as succeeded. It
+	// reports false when the SMS wasn't claimed.
+	CompleteSMS(ctx context.Context, smsID uint64) (bool, error)
+	// RecordAttempt stores how often delivery of an SMS was tried and the
+	// error of the last failed attempt.
+	RecordAttempt(ctx context.Context, smsID uint64, attempts int, lastError string) error
 }
 
 type TransactionService interface {
 	UpdateTransactionStatus(ctx context.Context, smsID uint64, status entity.TransactionStatusEnum) error
+	// ListTransactions returns a page of transactions and the cursor of the
+	// next page, which is 0 on the last page.
+	ListTransactions(ctx context.Context, filter TransactionFilter, cursor uint64, limit int) ([]entity.Transaction, uint64, error)
+	// ExportTransactions hands all transactions matching filter to fn in
+	// batches, newest first, stopping at the first error fn returns.
+	ExportTransactions(ctx context.Context, filter TransactionFilter, fn func(batch []entity.Transaction) error) error
+	MonthlyStatement(ctx context.Context, userID uint64, month time.Time) (*Statement, error)
+}
+
+// Statement summarizes a user's credit movements over one calendar month
+// (UTC), based on the ledger.
+type Statement struct {
+	UserID         uint64    `json:"user_id"`
+	Month          string    `json:"month"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening_balance"`
+	ClosingBalance int64     `json:"closing_balance"`
+	TopUps         int64     `json:"top_ups"`
+	Debits         int64     `json:"debits"`
+	Refunds        int64     `json:"refunds"`
+	Adjustments    int64     `json:"adjustments"`
+	Messages       int64     `json:"messages"` // debited messages
 }
 
 type UserService interface {
 	CreateUser(ctx context.Context, user *entity.User) (*entity.User, error)
 	UpdateCredit(ctx context.Context, userID uint64, amount uint32) (*entity.User, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*entity.User, *entity.APIKey, error)
+	ListUsers(ctx context.Context, filter UserFilter, page int, pageSize int) ([]entity.User, int64, error)
+	GetUserDetails(ctx context.Context, userID uint64) (*UserDetails, error)
+	SuspendUser(ctx context.Context, userID uint64, reason string) (*entity.User, error)
+	ReactivateUser(ctx context.Context, userID uint64) (*entity.User, error)
+	UpdateProfile(ctx context.Context, userID uint64, update UserProfileUpdate) (*entity.User, error)
+	UpdateLimits(ctx context.Context, userID uint64, limits UserLimits) (*entity.User, error)
+	UpdateBilling(ctx context.Context, userID uint64, billing UserBilling) (*entity.User, error)
+	// OutstandingBalances reports what postpaid users owe.
+	OutstandingBalances(ctx context.Context) (*OutstandingReport, error)
+	// SetWebhook sets the URL events are forwarded to and returns the new
+	// signing secret. An empty url removes the webhook.
+	SetWebhook(ctx context.Context, userID uint64, url string) (*entity.User, string, error)
+	// SetTimezone sets the IANA timezone the user's spending caps reset in.
+	SetTimezone(ctx context.Context, userID uint64, timezone string) (*entity.User, error)
+}
+
+type UserDetails struct {
+	User  *entity.User `json:"user"`
+	Usage *UserUsage   `json:"usage"`
+}
+
+type UserProfileUpdate struct {
+	Name        *string
+	PhoneNumber *string
+}
+
+type UserLimits struct {
+	Plan      string
+	RateLimit *int
+}
+
+type UserBilling struct {
+	AccountType entity.AccountTypeEnum
+	CreditLimit int64
+}
+
+type OutstandingBalance struct {
+	UserID      uint32 `json:"user_id"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+	Credit      int64  `json:"credit"`
+	CreditLimit int64  `json:"credit_limit"`
+	Outstanding int64  `json:"outstanding"`
+	Available   int64  `json:"available"` // what can still be spent before the limit
+}
+
+type OutstandingReport struct {
+	Accounts         []OutstandingBalance `json:"accounts"`
+	TotalOutstanding int64                `json:"total_outstanding"`
 }
 
 type MultiQueueConsumer interface {
@@ -29,7 +119,161 @@ type MultiQueueConsumer interface {
 }
 
 type QueueManager interface {
-	DetermineQueue(ctx context.Context) (string, error)
-	PublishToQueue(ctx context.Context, message connection.RabbitMQMessageBody) error
+	DetermineQueue(ctx context.Context, priority entity.SMSPriorityEnum, userID uint64) (string, error)
+	PublishToQueue(ctx context.Context, message connection.RabbitMQMessageBody, priority entity.SMSPriorityEnum, userID uint64) error
 	GetQueueNames() []string
-}
\ No newline at end of file
+}
+type SMSStatusPublisher interface {
+	PublishStatus(ctx context.Context, sms *entity.SMS) error
+}
+
+type SMSStatusStream interface {
+	SMSStatusPublisher
+	Subscribe(filter SMSStatusEventFilter) *SMSStatusSubscription
+	Unsubscribe(subscription *SMSStatusSubscription)
+	Replay(ctx context.Context, filter SMSStatusEventFilter, afterID uint64, limit int) ([]entity.SMSStatusEvent, error)
+	ReplayStart(ctx context.Context, filter SMSStatusEventFilter, lastEventID uint64) (uint64, error)
+}
+
+// SMSStatusSubscription receives live status events matching Filter. Events is
+// closed when the subscriber is dropped, e.g. because it fell too far behind.
+type SMSStatusSubscription struct {
+	ID     uint64
+	Filter SMSStatusEventFilter
+	Events chan entity.SMSStatusEvent
+}
+
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusUnavailable = "unavailable"
+)
+
+type HealthService interface {
+	Liveness(ctx context.Context) HealthReport
+	Readiness(ctx context.Context) HealthReport
+	SetShuttingDown()
+}
+
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type OTPService interface {
+	SendOTP(ctx context.Context, userID uint64, apiKeyID *uint64, receiveNumber string) (*entity.OTP, error)
+	VerifyOTP(ctx context.Context, userID uint64, receiveNumber string, code string) error
+}
+
+// InboundMessageInput is a mobile-originated message as reported by a
+// provider, either through the HTTP callback or an SMPP deliver_sm.
+type InboundMessageInput struct {
+	Provider          string
+	ProviderMessageID string
+	FromNumber        string
+	ToNumber          string
+	Message           string
+	ReceivedAt        time.Time
+}
+
+type InboundService interface {
+	Receive(ctx context.Context, input InboundMessageInput) (*entity.InboundMessage, error)
+	GetUserHistory(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.InboundMessage, error)
+	ListRoutes(ctx context.Context, userID uint64) ([]entity.InboundRoute, error)
+	CreateRoute(ctx context.Context, route *entity.InboundRoute) error
+	DeleteRoute(ctx context.Context, routeID uint64) error
+}
+
+// WebhookSender delivers signed event notifications to a user's webhook.
+type WebhookSender interface {
+	Send(ctx context.Context, user *entity.User, event string, payload any) error
+}
+
+type LedgerService interface {
+	ListEntries(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.LedgerEntry, int64, error)
+	Verify(ctx context.Context, userID uint64) (*LedgerVerification, error)
+	AdjustCredit(ctx context.Context, userID uint64, amount int64, reason string) (*entity.LedgerEntry, error)
+}
+
+// LedgerVerification compares a user's stored credit with their ledger. The
+// credit is consistent when it equals both the sum of the user's entries and
+// the balance after the latest one.
+type LedgerVerification struct {
+	UserID     uint64         `json:"user_id"`
+	Credit     int64          `json:"credit"`
+	Ledger     *LedgerSummary `json:"ledger"`
+	Consistent bool           `json:"consistent"`
+}
+
+type BalanceAlertService interface {
+	// GetSettings returns the user's alert settings, or disabled defaults when
+	// the user never configured alerts.
+	GetSettings(ctx context.Context, userID uint64) (*entity.BalanceAlertSetting, error)
+	UpdateSettings(ctx context.Context, setting *entity.BalanceAlertSetting) (*entity.BalanceAlertSetting, error)
+	ListAlerts(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.BalanceAlert, int64, error)
+	// Check alerts the user in the background when balance fell below their
+	// threshold and no alert went out within the cooldown.
+	Check(ctx context.Context, userID uint64, balance int64)
+	// Wait blocks until the alerts Check started have gone out, or until ctx
+	// is done. Alerts still in flight then are lost.
+	Wait(ctx context.Context)
+}
+
+type ReconciliationService interface {
+	// Reconcile cross-checks messages, transactions and balances, repairs the
+	// safe cases when repair is set and stores the report.
+	Reconcile(ctx context.Context, triggeredBy entity.ReconciliationTriggerEnum, repair bool) (*entity.ReconciliationReport, error)
+	ListReports(ctx context.Context, page int, pageSize int) ([]entity.ReconciliationReport, int64, error)
+	GetReport(ctx context.Context, id uint64) (*entity.ReconciliationReport, error)
+}
+
+type DeadLetterService interface {
+	// List returns up to limit messages parked in the dead-letter queue of
+	// queue, or of every SMS queue when it's empty, leaving them in place.
+	List(ctx context.Context, queue string, limit int) ([]DeadLetterMessage, error)
+	// Replay moves the message with messageID, or every message when it's
+	// empty, back to the queue it came from and returns how many it moved.
+	Replay(ctx context.Context, queue string, messageID string) (int, error)
+	// Purge drops the message with messageID, or every message when it's
+	// empty, and returns how many it dropped.
+	Purge(ctx context.Context, queue string, messageID string) (int, error)
+}
+
+// DeadLetterMessage is a message that couldn't be processed and was parked in
+// the dead-letter queue of the queue it came from.
+type DeadLetterMessage struct {
+	ID             string    `json:"id"`
+	Queue          string    `json:"queue"`
+	Reason         string    `json:"reason"`
+	Body           string    `json:"body"`
+	Attempts       int       `json:"attempts"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+}
+
+type SpendingCapService interface {
+	// Reserve counts a message costing amount against the caps of the user
+	// and the API key it's sent with, failing when any cap would be exceeded.
+	// It must run in the unit of work that debits the message.
+	Reserve(ctx context.Context, user *entity.User, apiKeyID *uint64, amount int64) error
+	// Release gives back what Reserve counted for a message reserved at
+	// reservedAt, once it's refunded. It must run in the unit of work that
+	// refunds the message.
+	Release(ctx context.Context, user *entity.User, apiKeyID *uint64, amount int64, reservedAt time.Time) error
+	ListCaps(ctx context.Context, userID uint64) ([]SpendingCapUsage, error)
+	// SetCap creates or replaces a cap. A cap without limits is removed.
+	SetCap(ctx context.Context, spendingCap *entity.SpendingCap) error
+}
+
+// SpendingCapUsage is a cap along with what was spent in its current period.
+type SpendingCapUsage struct {
+	entity.SpendingCap
+	PeriodStart  time.Time `json:"period_start"`
+	ResetsAt     time.Time `json:"resets_at"`
+	UsedAmount   int64     `json:"used_amount"`
+	UsedMessages int64     `json:"used_messages"`
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limiter enforces a sliding window limit of requests per key. Both
+// implementations use the sliding window counter algorithm: the count of the
+// previous fixed window is weighted by how much of it still overlaps the
+// sliding window, and added to the count of the current fixed window.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// windowStart returns the start of the fixed window now falls in, and how far
+// into that window now is.
+func windowStart(now time.Time, window time.Duration) (time.Time, time.Duration) {
+	start := now.Truncate(window)
+	return start, now.Sub(start)
+}
+
+// evaluate computes the limiter decision from the previous and current window
+// counts, before the current request is counted.
+func evaluate(previous, current int64, limit int, window, elapsed time.Duration) Result {
+	weight := float64(window-elapsed) / float64(window)
+	used := float64(previous)*weight + float64(current)
+
+	result := Result{
+		Limit:      limit,
+		ResetAfter: window - elapsed,
+	}
+
+	if used+1 > float64(limit) {
+		result.RetryAfter = retryAfter(previous, current, limit, window, elapsed)
+		return result
+	}
+
+	result.Allowed = true
+	result.Remaining = int(math.Max(0, math.Floor(float64(limit)-used-1)))
+	return result
+}
+
+// retryAfter estimates how long until one more request fits in the window,
+// assuming no other requests arrive in the meantime.
+func retryAfter(previous, current int64, limit int, window, elapsed time.Duration) time.Duration {
+	free := float64(limit) - float64(current) - 1
+	if free < 0 || previous == 0 {
+		// Only the next window frees up room for the current window's requests.
+		return window - elapsed + time.Second
+	}
+
+	// Solve previous*(window-t)/window <= free for t.
+	t := time.Duration(float64(window) * (1 - free/float64(previous)))
+	if t <= elapsed {
+		return time.Second
+	}
+	return t - elapsed
+}
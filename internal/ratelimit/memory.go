@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryWindow struct {
+	start    time.Time
+	previous int64
+	current  int64
+}
+
+type memoryLimiter struct {
+	mu          sync.Mutex
+	windows     map[string]*memoryWindow
+	lastEvicted time.Time
+	now         func() time.Time
+}
+
+// NewMemoryLimiter returns a limiter that keeps its counters in process
+// memory, so limits are enforced per instance only.
+func NewMemoryLimiter() Limiter {
+	return &memoryLimiter{
+		windows: make(map[string]*memoryWindow),
+		now:     time.Now,
+	}
+}
+
+func (l *memoryLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start, elapsed := windowStart(l.now(), window)
+
+	w, ok := l.windows[key]
+	if !ok {
+		w = &memoryWindow{start: start}
+		l.windows[key] = w
+	}
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(window).Equal(start):
+		w.start, w.previous, w.current = start, w.current, 0
+	default:
+		w.start, w.previous, w.current = start, 0, 0
+	}
+
+	result := evaluate(w.previous, w.current, limit, window, elapsed)
+	if result.Allowed {
+		w.current++
+	}
+
+	l.evictExpired(start, window)
+
+	return result, nil
+}
+
+// evictExpired drops counters that no longer affect any decision. It runs at
+// most once per window to keep Allow cheap.
+func (l *memoryLimiter) evictExpired(start time.Time, window time.Duration) {
+	if !start.After(l.lastEvicted) {
+		return
+	}
+	l.lastEvicted = start
+
+	for key, w := range l.windows {
+		if w.start.Add(window).Before(start) {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous int64
+		current  int64
+		limit    int
+		elapsed  time.Duration
+		want     Result
+	}{
+		{
+			name:  "empty window",
+			limit: 10,
+			want:  Result{Allowed: true, Limit: 10, Remaining: 9, ResetAfter: time.Minute},
+		},
+		{
+			name:     "previous window half weighted",
+			previous: 10,
+			limit:    10,
+			elapsed:  30 * time.Second,
+			want:     Result{Allowed: true, Limit: 10, Remaining: 4, ResetAfter: 30 * time.Second},
+		},
+		{
+			name:    "last request that fits",
+			current: 9,
+			limit:   10,
+			elapsed: 10 * time.Second,
+			want:    Result{Allowed: true, Limit: 10, Remaining: 0, ResetAfter: 50 * time.Second},
+		},
+		{
+			name:     "previous window fully weighted",
+			previous: 4,
+			limit:    4,
+			want:     Result{Limit: 4, ResetAfter: time.Minute, RetryAfter: 15 * time.Second},
+		},
+		{
+			name:     "previous window partly slid out",
+			previous: 4,
+			limit:    4,
+			elapsed:  6 * time.Second,
+			want:     Result{Limit: 4, ResetAfter: 54 * time.Second, RetryAfter: 9 * time.Second},
+		},
+		{
+			name:    "current window full",
+			current: 10,
+			limit:   10,
+			elapsed: 15 * time.Second,
+			want:    Result{Limit: 10, ResetAfter: 45 * time.Second, RetryAfter: 46 * time.Second},
+		},
+		{
+			name:     "current window full with previous",
+			previous: 10,
+			current:  10,
+			limit:    10,
+			elapsed:  15 * time.Second,
+			want:     Result{Limit: 10, ResetAfter: 45 * time.Second, RetryAfter: 46 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluate(tt.previous, tt.current, tt.limit, time.Minute, tt.elapsed)
+			if got != tt.want {
+				t.Errorf("evaluate(%d, %d, %d, 1m, %s) = %+v, want %+v", tt.previous, tt.current, tt.limit, tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := NewMemoryLimiter().(*memoryLimiter)
+	l.now = func() time.Time { return now }
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		key     string
+		want    Result
+	}{
+		{name: "first", key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 3, ResetAfter: time.Minute}},
+		{name: "second", key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 2, ResetAfter: time.Minute}},
+		{name: "third", key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 1, ResetAfter: time.Minute}},
+		{name: "fourth", key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 0, ResetAfter: time.Minute}},
+		{name: "over the limit", key: "a", want: Result{Limit: 4, ResetAfter: time.Minute, RetryAfter: 61 * time.Second}},
+		{name: "other key", key: "b", want: Result{Allowed: true, Limit: 4, Remaining: 3, ResetAfter: time.Minute}},
+		{name: "half of previous window counts", advance: 90 * time.Second, key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 1, ResetAfter: 30 * time.Second}},
+		{name: "fills the sliding window", key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 0, ResetAfter: 30 * time.Second}},
+		{name: "waits for the previous window to slide out", key: "a", want: Result{Limit: 4, ResetAfter: 30 * time.Second, RetryAfter: 15 * time.Second}},
+		{name: "idle windows are forgotten", advance: 2 * time.Minute, key: "a", want: Result{Allowed: true, Limit: 4, Remaining: 3, ResetAfter: 30 * time.Second}},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		got, err := l.Allow(context.Background(), step.key, 4, time.Minute)
+		if err != nil {
+			t.Fatalf("%s: Allow() error = %v", step.name, err)
+		}
+		if got != step.want {
+			t.Errorf("%s: Allow() = %+v, want %+v", step.name, got, step.want)
+		}
+	}
+
+	if _, ok := l.windows["b"]; ok {
+		t.Errorf("counter of idle key b was not evicted")
+	}
+}
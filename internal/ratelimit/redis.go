@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript reads both window counters and increments the current
+// one only when the request is allowed, atomically on the Redis side.
+var slidingWindowScript = redis.NewScript(`
+local previous = tonumber(redis.call("GET", KEYS[1]) or "0")
+local current = tonumber(redis.call("GET", KEYS[2]) or "0")
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local window_ms = tonumber(ARGV[3])
+
+if previous * weight + current + 1 > limit then
+	return {0, previous, current}
+end
+
+redis.call("INCR", KEYS[2])
+redis.call("PEXPIRE", KEYS[2], window_ms * 2)
+return {1, previous, current}
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+// NewRedisLimiter returns a limiter that shares its counters through Redis,
+// so limits hold across all gateway instances.
+func NewRedisLimiter(client *redis.Client) Limiter {
+	return &redisLimiter{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (l *redisLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	start, elapsed := windowStart(l.now(), window)
+	weight := float64(window-elapsed) / float64(window)
+
+	previousKey := fmt.Sprintf("%s:%d", key, start.Add(-window).UnixMilli())
+	currentKey := fmt.Sprintf("%s:%d", key, start.UnixMilli())
+
+	values, err := slidingWindowScript.Run(ctx, l.client, []string{previousKey, currentKey}, limit, weight, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return evaluate(values[1], values[2], limit, window, elapsed), nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type apiKeyRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewAPIKeyRepository(db *gorm.DB, logger *logger.Logger) port.APIKeyRepository {
+	return &apiKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, apiKey *entity.APIKey) error {
+	err := dbFromContext(ctx, r.db).Create(apiKey).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create api key", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	var apiKey entity.APIKey
+	err := dbFromContext(ctx, r.db).Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&apiKey).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func (r *apiKeyRepository) GetByID(ctx context.Context, id uint64) (*entity.APIKey, error) {
+	var apiKey entity.APIKey
+	err := dbFromContext(ctx, r.db).First(&apiKey, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type balanceAlertRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewBalanceAlertRepository(db *gorm.DB, logger *logger.Logger) port.BalanceAlertRepository {
+	return &balanceAlertRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *balanceAlertRepository) GetSettings(ctx context.Context, userID uint64) (*entity.BalanceAlertSetting, error) {
+	var setting entity.BalanceAlertSetting
+	err := dbFromContext(ctx, r.db).Where("user_id = ?", userID).First(&setting).Error
+	if err != nil {
+		return nil, err
+	}
+	return &setting, nil
+}
+
+// SaveSettings creates or replaces the user's settings, keeping the time of
+// the last alert so changing settings doesn't bypass the cooldown.
+func (r *balanceAlertRepository) SaveSettings(ctx context.Context, setting *entity.BalanceAlertSetting) error {
+	err := dbFromContext(ctx, r.db).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"threshold", "notify_sms", "notify_webhook", "enabled", "updated_at"}),
+	}).Create(setting).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to save balance alert settings", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *balanceAlertRepository) Claim(ctx context.Context, userID uint64, balance int64, notAlertedSince time.Time) (*entity.BalanceAlertSetting, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.BalanceAlertSetting{}).
+		Where("user_id = ? AND enabled = ? AND threshold > ?", userID, true, balance).
+		Where("last_alerted_at IS NULL OR last_alerted_at < ?", notAlertedSince).
+		Update("last_alerted_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to claim balance alert", "error", result.Error.Error())
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return r.GetSettings(ctx, userID)
+}
+
+func (r *balanceAlertRepository) CreateAlert(ctx context.Context, alert *entity.BalanceAlert) error {
+	err := dbFromContext(ctx, r.db).Create(alert).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create balance alert", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *balanceAlertRepository) ListAlerts(ctx context.Context, userID uint64, limit int, offset int) ([]entity.BalanceAlert, int64, error) {
+	query := dbFromContext(ctx, r.db).Model(&entity.BalanceAlert{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error(ctx, "Failed to count balance alerts", "error", err.Error())
+		return nil, 0, err
+	}
+
+	var alerts []entity.BalanceAlert
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&alerts).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list balance alerts", "error", err.Error())
+		return nil, 0, err
+	}
+	return alerts, total, nil
+}
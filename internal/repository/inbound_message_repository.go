@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type inboundMessageRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewInboundMessageRepository(db *gorm.DB, logger *logger.Logger) port.InboundMessageRepository {
+	return &inboundMessageRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *inboundMessageRepository) Create(ctx context.Context, message *entity.InboundMessage) error {
+	err := dbFromContext(ctx, r.db).Create(message).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create inbound message", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *inboundMessageRepository) GetByProviderMessageID(ctx context.Context, provider string, providerMessageID string) (*entity.InboundMessage, error) {
+	var message entity.InboundMessage
+	err := dbFromContext(ctx, r.db).
+		Where("provider = ? AND provider_message_id = ?", provider, providerMessageID).
+		First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *inboundMessageRepository) GetUserHistory(ctx context.Context, userID uint64, limit int, offset int) ([]entity.InboundMessage, error) {
+	var messages []entity.InboundMessage
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get inbound message history", "error", err.Error())
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *inboundMessageRepository) ListDueForForward(ctx context.Context, limit int) ([]entity.InboundMessage, error) {
+	var messages []entity.InboundMessage
+	err := dbFromContext(ctx, r.db).
+		Where("forward_status = ? AND (next_forward_at IS NULL OR next_forward_at <= ?)", entity.InboundForwardPending, time.Now()).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list inbound messages due for forwarding", "error", err.Error())
+		return nil, err
+	}
+	return messages, nil
+}
+
+// ClaimForward leases a pending message to the caller until the given time,
+// so other instances skip it meanwhile. It reports whether the claim succeeded.
+func (r *inboundMessageRepository) ClaimForward(ctx context.Context, messageID uint64, until time.Time) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.InboundMessage{}).
+		Where("id = ? AND forward_status = ? AND (next_forward_at IS NULL OR next_forward_at <= ?)", messageID, entity.InboundForwardPending, time.Now()).
+		Update("next_forward_at", until)
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to claim inbound message", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *inboundMessageRepository) RecordForwardAttempt(ctx context.Context, messageID uint64, status entity.InboundForwardStatusEnum, forwardError string, nextForwardAt *time.Time) error {
+	fields := map[string]any{
+		"forward_status":   status,
+		"forward_attempts": gorm.Expr("forward_attempts + 1"),
+		"forward_error":    forwardError,
+		"next_forward_at":  nextForwardAt,
+	}
+	if status == entity.InboundForwardDelivered {
+		fields["forwarded_at"] = time.Now()
+	}
+
+	err := dbFromContext(ctx, r.db).Model(&entity.InboundMessage{}).Where("id = ?", messageID).Updates(fields).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to record inbound forward attempt", "error", err.Error())
+		return err
+	}
+	return nil
+}
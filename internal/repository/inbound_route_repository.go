@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type inboundRouteRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewInboundRouteRepository(db *gorm.DB, logger *logger.Logger) port.InboundRouteRepository {
+	return &inboundRouteRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *inboundRouteRepository) Create(ctx context.Context, route *entity.InboundRoute) error {
+	err := dbFromContext(ctx, r.db).Create(route).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create inbound route", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *inboundRouteRepository) Delete(ctx context.Context, routeID uint64) (bool, error) {
+	result := dbFromContext(ctx, r.db).Delete(&entity.InboundRoute{}, routeID)
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to delete inbound route", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *inboundRouteRepository) List(ctx context.Context, userID uint64) ([]entity.InboundRoute, error) {
+	var routes []entity.InboundRoute
+	query := dbFromContext(ctx, r.db)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	err := query.Order("id ASC").Find(&routes).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list inbound routes", "error", err.Error())
+		return nil, err
+	}
+	return routes, nil
+}
+
+func (r *inboundRouteRepository) ListByNumber(ctx context.Context, number string) ([]entity.InboundRoute, error) {
+	var routes []entity.InboundRoute
+	err := dbFromContext(ctx, r.db).Where("number = ?", number).Find(&routes).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list inbound routes by number", "error", err.Error())
+		return nil, err
+	}
+	return routes, nil
+}
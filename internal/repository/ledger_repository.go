@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewLedgerRepository(db *gorm.DB, logger *logger.Logger) port.LedgerRepository {
+	return &ledgerRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Post locks the user's row for the rest of the transaction, so postings of
+// a user are serialized and every entry's balance_after follows from the one
+// before it. Debits may not take the balance below the user's minimum: zero
+// for prepaid accounts, minus the credit limit for postpaid ones.
+func (r *ledgerRepository) Post(ctx context.Context, posting port.LedgerPosting) (*entity.LedgerEntry, error) {
+	var entry *entity.LedgerEntry
+	err := dbFromContext(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var user entity.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "credit", "account_type", "credit_limit").
+			First(&user, posting.UserID).Error
+		if err != nil {
+			return err
+		}
+
+		balance := user.Credit + posting.Amount
+		if posting.Amount < 0 && balance < user.MinimumBalance() {
+			return nil
+		}
+
+		if err := tx.Model(&entity.User{}).Where("id = ?", posting.UserID).Update("credit", balance).Error; err != nil {
+			return err
+		}
+
+		journalID, err := newJournalID()
+		if err != nil {
+			return err
+		}
+
+		userID := posting.UserID
+		entries := []entity.LedgerEntry{
+			{
+				JournalID:     journalID,
+				Account:       entity.UserLedgerAccount(userID),
+				UserID:        &userID,
+				Type:          posting.Type,
+				Amount:        posting.Amount,
+				BalanceAfter:  &balance,
+				ReferenceType: posting.ReferenceType,
+				ReferenceID:   posting.ReferenceID,
+				Description:   posting.Description,
+			},
+			{
+				JournalID:     journalID,
+				Account:       entity.LedgerContraAccount(posting.Type),
+				Type:          posting.Type,
+				Amount:        -posting.Amount,
+				ReferenceType: posting.ReferenceType,
+				ReferenceID:   posting.ReferenceID,
+				Description:   posting.Description,
+			},
+		}
+		if err := tx.Create(&entries).Error; err != nil {
+			return err
+		}
+
+		entry = &entries[0]
+		return nil
+	})
+	if err != nil {
+		r.logger.Error(ctx, "Failed to post ledger entry", "error", err.Error(), "user_id", posting.UserID, "type", posting.Type)
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *ledgerRepository) ListByUser(ctx context.Context, userID uint64, limit int, offset int) ([]entity.LedgerEntry, int64, error) {
+	query := dbFromContext(ctx, r.db).Model(&entity.LedgerEntry{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error(ctx, "Failed to count ledger entries", "error", err.Error())
+		return nil, 0, err
+	}
+
+	var entries []entity.LedgerEntry
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list ledger entries", "error", err.Error())
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *ledgerRepository) Summarize(ctx context.Context, userID uint64) (*port.LedgerSummary, error) {
+	var summary port.LedgerSummary
+	err := dbFromContext(ctx, r.db).Model(&entity.LedgerEntry{}).
+		Select("COUNT(*) AS entries, COALESCE(SUM(amount), 0) AS sum").
+		Where("user_id = ?", userID).
+		Scan(&summary).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to summarize ledger", "error", err.Error())
+		return nil, err
+	}
+
+	var last entity.LedgerEntry
+	err = dbFromContext(ctx, r.db).Where("user_id = ?", userID).Order("id DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get last ledger entry", "error", err.Error())
+		return nil, err
+	}
+	summary.LastBalanceAfter = last.BalanceAfter
+
+	return &summary, nil
+}
+
+func (r *ledgerRepository) BalanceBefore(ctx context.Context, userID uint64, t time.Time) (int64, error) {
+	var last entity.LedgerEntry
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND created_at < ?", userID, t).
+		Order("id DESC").
+		Limit(1).
+		Find(&last).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get balance from ledger", "error", err.Error())
+		return 0, err
+	}
+	if last.BalanceAfter == nil {
+		return 0, nil
+	}
+	return *last.BalanceAfter, nil
+}
+
+func (r *ledgerRepository) TotalsByType(ctx context.Context, userID uint64, from time.Time, to time.Time) (map[entity.LedgerEntryTypeEnum]port.LedgerTotal, error) {
+	var rows []struct {
+		Type    entity.LedgerEntryTypeEnum
+		Entries int64
+		Amount  int64
+	}
+	err := dbFromContext(ctx, r.db).Model(&entity.LedgerEntry{}).
+		Select("type, COUNT(*) AS entries, COALESCE(SUM(amount), 0) AS amount").
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from, to).
+		Group("type").
+		Scan(&rows).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to total ledger entries", "error", err.Error())
+		return nil, err
+	}
+
+	totals := make(map[entity.LedgerEntryTypeEnum]port.LedgerTotal, len(rows))
+	for _, row := range rows {
+		totals[row.Type] = port.LedgerTotal{Entries: row.Entries, Amount: row.Amount}
+	}
+	return totals, nil
+}
+
+func newJournalID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
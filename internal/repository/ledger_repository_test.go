@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the MySQL database in TEST_MYSQL_DSN, e.g.
+// "root:root@tcp(localhost:3306)/sms_gateway_test?parseTime=True". Tests that
+// need it are skipped when it isn't set.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to connect to mysql: %v", err)
+	}
+	if err := db.AutoMigrate(&entity.User{}, &entity.LedgerEntry{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestPostConcurrentDebitsDontOverdraw(t *testing.T) {
+	const (
+		cost    = 1000
+		covered = 10 // debits the starting credit pays for
+		debits  = 50
+	)
+
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	user := &entity.User{
+		Name:        "overdraw test",
+		PhoneNumber: fmt.Sprintf("+1%d", time.Now().UnixNano()),
+		Credit:      cost * covered,
+		AccountType: entity.AccountTypePrepaid,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	userID := uint64(user.ID)
+	t.Cleanup(func() {
+		var journals []string
+		db.Model(&entity.LedgerEntry{}).Where("user_id = ?", userID).Pluck("journal_id", &journals)
+		if len(journals) > 0 {
+			db.Where("journal_id IN ?", journals).Delete(&entity.LedgerEntry{})
+		}
+		db.Delete(&entity.User{}, userID)
+	})
+
+	log := logger.New()
+	unitOfWork := NewUnitOfWork(db)
+	ledgerRepo := NewLedgerRepository(db, log)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		declined  int
+		failures  []error
+	)
+	start := make(chan struct{})
+	for range debits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			// Debit the way SendSMS does: inside a unit of work that rolls
+			// back when the credit doesn't cover it.
+			err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+				entry, err := ledgerRepo.Post(ctx, port.LedgerPosting{
+					UserID:        userID,
+					Type:          entity.LedgerEntryDebit,
+					Amount:        -cost,
+					ReferenceType: entity.LedgerReferenceSMS,
+					ReferenceID:   userID,
+				})
+				if err != nil {
+					return err
+				}
+				if entry == nil {
+					return errors.ErrInsufficientCredit
+				}
+				return nil
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case stderrors.Is(err, errors.ErrInsufficientCredit):
+				declined++
+			default:
+				failures = append(failures, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("debits failed: %v", failures)
+	}
+	if succeeded != covered {
+		t.Errorf("succeeded debits = %d, want %d", succeeded, covered)
+	}
+	if declined != debits-covered {
+		t.Errorf("declined debits = %d, want %d", declined, debits-covered)
+	}
+
+	var reloaded entity.User
+	if err := db.First(&reloaded, userID).Error; err != nil {
+		t.Fatalf("failed to reload user: %v", err)
+	}
+	if reloaded.Credit != 0 {
+		t.Errorf("credit = %d, want 0", reloaded.Credit)
+	}
+
+	var entries []entity.LedgerEntry
+	if err := db.Where("user_id = ?", userID).Find(&entries).Error; err != nil {
+		t.Fatalf("failed to list ledger entries: %v", err)
+	}
+	if len(entries) != covered {
+		t.Errorf("ledger entries = %d, want %d", len(entries), covered)
+	}
+	for _, entry := range entries {
+		if entry.BalanceAfter == nil || *entry.BalanceAfter < 0 {
+			t.Errorf("entry %d left balance %v, want >= 0", entry.ID, entry.BalanceAfter)
+		}
+	}
+}
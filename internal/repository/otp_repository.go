@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type otpRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewOTPRepository(db *gorm.DB, logger *logger.Logger) port.OTPRepository {
+	return &otpRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *otpRepository) Create(ctx context.Context, otp *entity.OTP) error {
+	err := dbFromContext(ctx, r.db).Create(otp).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create otp", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *otpRepository) GetLatest(ctx context.Context, userID uint64, receiveNumber string) (*entity.OTP, error) {
+	var otp entity.OTP
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND receive_number = ?", userID, receiveNumber).
+		Order("id DESC").
+		First(&otp).Error
+	if err != nil {
+		return nil, err
+	}
+	return &otp, nil
+}
+
+func (r *otpRepository) SetSMSID(ctx context.Context, otpID uint64, smsID uint64) error {
+	err := dbFromContext(ctx, r.db).Model(&entity.OTP{}).Where("id = ?", otpID).Update("sms_id", smsID).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to set otp sms id", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *otpRepository) Expire(ctx context.Context, otpID uint64) error {
+	err := dbFromContext(ctx, r.db).Model(&entity.OTP{}).Where("id = ?", otpID).Update("expires_at", time.Now()).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to expire otp", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+// IncrementAttempts counts a verification attempt, unless the otp has used up
+// its attempts or was already verified. It reports whether the attempt counted.
+func (r *otpRepository) IncrementAttempts(ctx context.Context, otpID uint64) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.OTP{}).
+		Where("id = ? AND attempts < max_attempts AND verified_at IS NULL", otpID).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to increment otp attempts", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkVerified marks the otp as verified. It reports false when it already was,
+// so a code can only be used once.
+func (r *otpRepository) MarkVerified(ctx context.Context, otpID uint64) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.OTP{}).
+		Where("id = ? AND verified_at IS NULL", otpID).
+		Update("verified_at", time.Now())
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to mark otp verified", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewOutboxRepository(db *gorm.DB, logger *logger.Logger) port.OutboxRepository {
+	return &outboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *outboxRepository) Create(ctx context.Context, message *entity.OutboxMessage) error {
+	if message.AvailableAt.IsZero() {
+		message.AvailableAt = time.Now()
+	}
+	err := dbFromContext(ctx, r.db).Create(message).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create outbox message", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+// LockDueByPriority, like LockDueForUser, locks rows with FOR UPDATE SKIP
+// LOCKED, so relays running at the same time each get different messages
+// instead of waiting on each other.
+func (r *outboxRepository) LockDueByPriority(ctx context.Context, priority entity.SMSPriorityEnum, limit int) ([]entity.OutboxMessage, error) {
+	var messages []entity.OutboxMessage
+	err := r.due(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("priority = ?", priority).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to lock due outbox messages", "error", err.Error())
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) LockDueForUser(ctx context.Context, userID uint64, limit int) ([]entity.OutboxMessage, error) {
+	var messages []entity.OutboxMessage
+	err := r.due(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("user_id = ? AND priority <> ?", userID, entity.SMSPriorityHigh).
+		Order("id ASC").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to lock due outbox messages of user", "error", err.Error(), "user_id", userID)
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) ListDueTenants(ctx context.Context, fromUserID uint64, limit int) ([]port.OutboxTenant, error) {
+	var tenants []port.OutboxTenant
+	err := r.due(ctx).
+		Select("outbox_messages.user_id, users.plan").
+		Joins("JOIN users ON users.id = outbox_messages.user_id").
+		Where("outbox_messages.priority <> ? AND outbox_messages.user_id >= ?", entity.SMSPriorityHigh, fromUserID).
+		Group("outbox_messages.user_id, users.plan").
+		Order("outbox_messages.user_id ASC").
+		Limit(limit).
+		Scan(&tenants).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list outbox tenants", "error", err.Error())
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (r *outboxRepository) MarkDispatched(ctx context.Context, ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := dbFromContext(ctx, r.db).Model(&entity.OutboxMessage{}).
+		Where("id IN ?", ids).
+		Update("dispatched_at", time.Now()).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to mark outbox messages dispatched", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *outboxRepository) Reschedule(ctx context.Context, id uint64, availableAt time.Time, lastError string) error {
+	err := dbFromContext(ctx, r.db).Model(&entity.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"attempts":     gorm.Expr("attempts + 1"),
+			"available_at": availableAt,
+			"last_error":   lastError,
+		}).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to reschedule outbox message", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *outboxRepository) DeleteDispatched(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result := dbFromContext(ctx, r.db).
+		Where("dispatched_at IS NOT NULL AND dispatched_at < ?", before).
+		Limit(limit).
+		Delete(&entity.OutboxMessage{})
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to delete dispatched outbox messages", "error", result.Error.Error())
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// due scopes a query to undispatched messages that are due.
+func (r *outboxRepository) due(ctx context.Context) *gorm.DB {
+	return dbFromContext(ctx, r.db).Model(&entity.OutboxMessage{}).
+		Where("outbox_messages.dispatched_at IS NULL AND outbox_messages.available_at <= ?", time.Now())
+}
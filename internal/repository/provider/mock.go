@@ -1,10 +1,12 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"time"
 
@@ -14,6 +16,12 @@ import (
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
 
+type mockSendRequest struct {
+	ReceiveNumber  string `json:"receive_number"`
+	Message        string `json:"message"`
+	ValidityPeriod int    `json:"validity_period,omitempty"` // seconds
+}
+
 type ProviderMock struct {
 	logger *logger.Logger
 	config *config.Config
@@ -33,10 +41,24 @@ func (p *ProviderMock) Send(ctx context.Context, sms *entity.SMS) (*port.SendRes
 
 	url := fmt.Sprintf("http://%s:%d/mock/sms", p.config.Mock.Host, p.config.Mock.Port)
 
-	req, err := http.NewRequest("POST", url, nil)
+	payload := mockSendRequest{
+		ReceiveNumber: sms.ReceiveNumber,
+		Message:       sms.Message,
+	}
+	if sms.ExpiresAt != nil {
+		// The provider stops trying to deliver once the validity period ends.
+		payload.ValidityPeriod = max(int(math.Ceil(time.Until(*sms.ExpiresAt).Seconds())), 1)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
 	res, err := p.client.Do(req.WithContext(ctx))
 	if err != nil {
@@ -50,6 +72,12 @@ func (p *ProviderMock) Send(ctx context.Context, sms *entity.SMS) (*port.SendRes
 		}
 	}(res.Body)
 
+	// Throttling and server errors are transient, so report them as errors
+	// to have the message retried rather than as a rejection.
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("provider is unavailable: status %d", res.StatusCode)
+	}
+
 	var response port.SendResponse
 	err = json.NewDecoder(res.Body).Decode(&response)
 	if err != nil {
@@ -63,3 +91,23 @@ func (p *ProviderMock) Send(ctx context.Context, sms *entity.SMS) (*port.SendRes
 func (p *ProviderMock) DeliveryReport(ctx context.Context, sms *entity.SMS) (any, error) {
 	return nil, nil
 }
+
+func (p *ProviderMock) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("http://%s:%d/mock/health", p.config.Mock.Host, p.config.Mock.Port)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider health check returned status %d", res.StatusCode)
+	}
+	return nil
+}
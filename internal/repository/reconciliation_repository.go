@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type reconciliationRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewReconciliationRepository(db *gorm.DB, logger *logger.Logger) port.ReconciliationRepository {
+	return &reconciliationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *reconciliationRepository) FindOrphanedTransactions(ctx context.Context, limit int) ([]entity.Transaction, error) {
+	var transactions []entity.Transaction
+	err := dbFromContext(ctx, r.db).
+		Where("operation = ? AND sms_id IS NULL", entity.Decrease).
+		Order("id").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to find orphaned transactions", "error", err.Error())
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *reconciliationRepository) FindUnbilledSMS(ctx context.Context, before time.Time, limit int) ([]entity.SMS, error) {
+	var messages []entity.SMS
+	err := dbFromContext(ctx, r.db).
+		Where("cost > 0 AND created_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM transactions t WHERE t.sms_id = sms.id AND t.operation = ?)", entity.Decrease).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to find unbilled sms", "error", err.Error())
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *reconciliationRepository) FindStatusMismatches(ctx context.Context, before time.Time, limit int) ([]port.SMSTransactionMismatch, error) {
+	refunded := "EXISTS (SELECT 1 FROM ledger_entries l WHERE l.user_id IS NOT NULL AND l.type = 'REFUND' AND l.reference_type = 'sms' AND l.reference_id = s.id)"
+	closed := []entity.SMSStatusEnum{entity.SMSStatusFailed, entity.SMSStatusCancelled, entity.SMSStatusExpired}
+
+	var mismatches []port.SMSTransactionMismatch
+	err := dbFromContext(ctx, r.db).
+		Table("sms AS s").
+		Select("s.id AS sms_id, s.user_id, s.status AS sms_status, t.id AS transaction_id, t.status AS transaction_status, "+refunded+" AS refunded").
+		Joins("JOIN transactions t ON t.sms_id = s.id AND t.operation = ?", entity.Decrease).
+		Where("s.updated_at < ?", before).
+		Where(
+			dbFromContext(ctx, r.db).
+				Where("s.status = ? AND t.status <> ?", entity.SMSStatusSent, entity.TransactionSuccess).
+				Or("s.status = ? AND t.status <> ?", entity.SMSStatusPending, entity.TransactionPending).
+				Or("s.status = ?", entity.SMSStatusSending).
+				Or("s.status IN ? AND (t.status <> ? OR NOT "+refunded+")", closed, entity.TransactionFailed),
+		).
+		Order("s.id").
+		Limit(limit).
+		Scan(&mismatches).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to find sms status mismatches", "error", err.Error())
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+func (r *reconciliationRepository) FindBalanceDrift(ctx context.Context, limit int) ([]port.BalanceDrift, error) {
+	var drifts []port.BalanceDrift
+	err := dbFromContext(ctx, r.db).
+		Table("users AS u").
+		Select("u.id AS user_id, u.credit, COALESCE(SUM(l.amount), 0) AS ledger_balance").
+		Joins("LEFT JOIN ledger_entries l ON l.user_id = u.id").
+		Group("u.id, u.credit").
+		Having("u.credit <> COALESCE(SUM(l.amount), 0)").
+		Order("u.id").
+		Limit(limit).
+		Scan(&drifts).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to find balance drift", "error", err.Error())
+		return nil, err
+	}
+	return drifts, nil
+}
+
+func (r *reconciliationRepository) CreateReport(ctx context.Context, report *entity.ReconciliationReport) error {
+	err := dbFromContext(ctx, r.db).Create(report).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create reconciliation report", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *reconciliationRepository) ListReports(ctx context.Context, limit int, offset int) ([]entity.ReconciliationReport, int64, error) {
+	query := dbFromContext(ctx, r.db).Model(&entity.ReconciliationReport{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error(ctx, "Failed to count reconciliation reports", "error", err.Error())
+		return nil, 0, err
+	}
+
+	var reports []entity.ReconciliationReport
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&reports).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list reconciliation reports", "error", err.Error())
+		return nil, 0, err
+	}
+	return reports, total, nil
+}
+
+func (r *reconciliationRepository) GetReport(ctx context.Context, id uint64) (*entity.ReconciliationReport, error) {
+	var report entity.ReconciliationReport
+	err := dbFromContext(ctx, r.db).Preload("Findings").First(&report, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
@@ -22,7 +23,7 @@ func NewSMSRepository(db *gorm.DB, logger *logger.Logger) port.SMSRepository {
 }
 
 func (r *smsRepository) Create(ctx context.Context, sms *entity.SMS) error {
-	err := r.db.WithContext(ctx).Create(sms).Error
+	err := dbFromContext(ctx, r.db).Create(sms).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to create sms", "error", err.Error())
 		return err
@@ -32,7 +33,7 @@ func (r *smsRepository) Create(ctx context.Context, sms *entity.SMS) error {
 
 func (r *smsRepository) GetByID(ctx context.Context, id uint64) (*entity.SMS, error) {
 	var sms entity.SMS
-	err := r.db.WithContext(ctx).First(&sms, id).Error
+	err := dbFromContext(ctx, r.db).First(&sms, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +41,7 @@ func (r *smsRepository) GetByID(ctx context.Context, id uint64) (*entity.SMS, er
 }
 
 func (r *smsRepository) Update(ctx context.Context, sms *entity.SMS) error {
-	err := r.db.WithContext(ctx).Save(sms).Error
+	err := dbFromContext(ctx, r.db).Save(sms).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to update sms", "error", err.Error())
 		return err
@@ -50,7 +51,7 @@ func (r *smsRepository) Update(ctx context.Context, sms *entity.SMS) error {
 
 func (r *smsRepository) UserHistory(ctx context.Context, userID uint64, limit int, offset int) ([]entity.SMS, error) {
 	var smsList []entity.SMS
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&smsList).Error
+	err := dbFromContext(ctx, r.db).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&smsList).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to get user history", "error", err.Error())
 		return nil, err
@@ -59,10 +60,128 @@ func (r *smsRepository) UserHistory(ctx context.Context, userID uint64, limit in
 }
 
 func (r *smsRepository) UpdateStatus(ctx context.Context, smsID uint64, status entity.SMSStatusEnum) error {
-	err := r.db.WithContext(ctx).Model(&entity.SMS{}).Where("id = ?", smsID).Update("status", status).Error
+	err := dbFromContext(ctx, r.db).Model(&entity.SMS{}).Where("id = ?", smsID).Update("status", status).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to update sms status", "error", err.Error())
 		return err
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// ClosePending moves a pending SMS to a final status, recording reason. It
+// reports false when the SMS wasn't pending anymore, so a message is only
+// closed once.
+func (r *smsRepository) ClosePending(ctx context.Context, smsID uint64, status entity.SMSStatusEnum, reason string) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.SMS{}).
+		Where("id = ? AND status = ?", smsID, entity.SMSStatusPending).
+		Updates(map[string]any{
+			"status":         status,
+			"failure_reason": reason,
+		})
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to close pending sms", "error", result.Error.Error(), "sms_id", smsID)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *smsRepository) RecordAttempt(ctx context.Context, smsID uint64, attempts int, lastError string) error {
+	err := dbFromContext(ctx, r.db).Model(&entity.SMS{}).
+		Where("id = ?", smsID).
+		Updates(map[string]any{
+			"attempts":   attempts,
+			"last_error": lastError,
+		}).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to record sms attempt", "error", err.Error(), "sms_id", smsID)
+		return err
+	}
+	return nil
+}
+
+func (r *smsRepository) ListExpiredPending(ctx context.Context, before time.Time, limit int) ([]entity.SMS, error) {
+	var smsList []entity.SMS
+	err := dbFromContext(ctx, r.db).
+		Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", entity.SMSStatusPending, before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&smsList).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list expired pending sms", "error", err.Error())
+		return nil, err
+	}
+	return smsList, nil
+}
+
+
+// Claim moves a pending SMS to SENDING for the worker about to hand it to the
+// provider. With takeover it also claims an SMS already in SENDING, whose
+// previous worker went away before finishing. It reports whether the SMS was
+// claimed.
+func (r *smsRepository) Claim(ctx context.Context, smsID uint64, takeover bool) (bool, error) {
+	from := []entity.SMSStatusEnum{entity.SMSStatusPending}
+	if takeover {
+		from = append(from, entity.SMSStatusSending)
+	}
+	return r.transition(ctx, smsID, from, entity.SMSStatusSending)
+}
+
+// ReleaseClaim moves a claimed SMS back to PENDING, so it can be cancelled,
+// expired or retried again.
+func (r *smsRepository) ReleaseClaim(ctx context.Context, smsID uint64) (bool, error) {
+	return r.transition(ctx, smsID, []entity.SMSStatusEnum{entity.SMSStatusSending}, entity.SMSStatusPending)
+}
+
+// MarkSent moves a claimed SMS to SENT. It reports false when the SMS wasn't
+// claimed.
+func (r *smsRepository) MarkSent(ctx context.Context, smsID uint64) (bool, error) {
+	return r.transition(ctx, smsID, []entity.SMSStatusEnum{entity.SMSStatusSending}, entity.SMSStatusSent)
+}
+
+func (r *smsRepository) transition(ctx context.Context, smsID uint64, from []entity.SMSStatusEnum, to entity.SMSStatusEnum) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.SMS{}).
+		Where("id = ? AND status IN ?", smsID, from).
+		Update("status", to)
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to update sms status", "error", result.Error.Error(), "sms_id", smsID, "status", to)
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *smsRepository) UsageByUser(ctx context.Context, userID uint64) (*port.UserUsage, error) {
+	var rows []struct {
+		Status entity.SMSStatusEnum
+		Count  int64
+		Cost   int64
+	}
+	err := dbFromContext(ctx, r.db).Model(&entity.SMS{}).
+		Select("status, COUNT(*) AS count, COALESCE(SUM(cost), 0) AS cost").
+		Where("user_id = ?", userID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get user usage", "error", err.Error())
+		return nil, err
+	}
+
+	usage := &port.UserUsage{}
+	for _, row := range rows {
+		usage.TotalMessages += row.Count
+		switch row.Status {
+		case entity.SMSStatusPending, entity.SMSStatusSending:
+			usage.PendingMessages += row.Count
+			usage.TotalSpent += row.Cost
+		case entity.SMSStatusSent:
+			usage.SentMessages = row.Count
+			usage.TotalSpent += row.Cost
+		case entity.SMSStatusFailed:
+			usage.FailedMessages = row.Count
+		case entity.SMSStatusCancelled:
+			usage.CancelledMessages = row.Count
+		case entity.SMSStatusExpired:
+			usage.ExpiredMessages = row.Count
+		}
+	}
+	return usage, nil
+}
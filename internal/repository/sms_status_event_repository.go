@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type smsStatusEventRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewSMSStatusEventRepository(db *gorm.DB, logger *logger.Logger) port.SMSStatusEventRepository {
+	return &smsStatusEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *smsStatusEventRepository) Create(ctx context.Context, event *entity.SMSStatusEvent) error {
+	err := dbFromContext(ctx, r.db).Create(event).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to create sms status event", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *smsStatusEventRepository) ListAfter(ctx context.Context, filter port.SMSStatusEventFilter, afterID uint64, limit int) ([]entity.SMSStatusEvent, error) {
+	var events []entity.SMSStatusEvent
+	query := dbFromContext(ctx, r.db).Where("user_id = ? AND id > ?", filter.UserID, afterID)
+	if filter.BatchID != "" {
+		query = query.Where("batch_id = ?", filter.BatchID)
+	}
+	err := query.Order("id ASC").Limit(limit).Find(&events).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list sms status events", "error", err.Error())
+		return nil, err
+	}
+	return events, nil
+}
+
+// OverlapStart returns the id to replay from so that events created up to
+// window before event afterID are sent again. Ids are assigned before commit,
+// so an event with a lower id can become visible after a client has already
+// seen a higher one.
+func (r *smsStatusEventRepository) OverlapStart(ctx context.Context, filter port.SMSStatusEventFilter, afterID uint64, window time.Duration) (uint64, error) {
+	db := dbFromContext(ctx, r.db)
+
+	var last entity.SMSStatusEvent
+	err := db.Where("id = ? AND user_id = ?", afterID, filter.UserID).Limit(1).Find(&last).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get sms status event", "error", err.Error())
+		return 0, err
+	}
+	if last.ID == 0 {
+		return afterID, nil
+	}
+
+	var first *uint64
+	query := db.Model(&entity.SMSStatusEvent{}).
+		Where("user_id = ? AND id < ? AND created_at >= ?", filter.UserID, afterID, last.CreatedAt.Add(-window))
+	if filter.BatchID != "" {
+		query = query.Where("batch_id = ?", filter.BatchID)
+	}
+	err = query.Select("MIN(id)").Scan(&first).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to find sms status replay overlap", "error", err.Error())
+		return 0, err
+	}
+	if first == nil {
+		return afterID, nil
+	}
+	return *first - 1, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type spendingCapRepository struct {
+	db     *gorm.DB
+	logger *logger.Logger
+}
+
+func NewSpendingCapRepository(db *gorm.DB, logger *logger.Logger) port.SpendingCapRepository {
+	return &spendingCapRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *spendingCapRepository) ListByUser(ctx context.Context, userID uint64) ([]entity.SpendingCap, error) {
+	var caps []entity.SpendingCap
+	err := dbFromContext(ctx, r.db).Where("user_id = ?", userID).Order("api_key_id, period").Find(&caps).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list spending caps", "error", err.Error())
+		return nil, err
+	}
+	return caps, nil
+}
+
+func (r *spendingCapRepository) ListApplicable(ctx context.Context, userID uint64, apiKeyID uint64) ([]entity.SpendingCap, error) {
+	var caps []entity.SpendingCap
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND api_key_id IN ?", userID, []uint64{0, apiKeyID}).
+		Order("api_key_id, period").
+		Find(&caps).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list applicable spending caps", "error", err.Error())
+		return nil, err
+	}
+	return caps, nil
+}
+
+func (r *spendingCapRepository) Save(ctx context.Context, spendingCap *entity.SpendingCap) error {
+	err := dbFromContext(ctx, r.db).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"max_amount", "max_messages", "updated_at"}),
+	}).Create(spendingCap).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to save spending cap", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *spendingCapRepository) Delete(ctx context.Context, userID uint64, apiKeyID uint64, period entity.SpendingCapPeriodEnum) error {
+	err := dbFromContext(ctx, r.db).
+		Where("user_id = ? AND api_key_id = ? AND period = ?", userID, apiKeyID, period).
+		Delete(&entity.SpendingCap{}).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to delete spending cap", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (r *spendingCapRepository) GetUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string) (*entity.SpendingUsage, error) {
+	usage := entity.SpendingUsage{
+		UserID:    spendingCap.UserID,
+		APIKeyID:  spendingCap.APIKeyID,
+		Period:    spendingCap.Period,
+		PeriodKey: periodKey,
+	}
+	err := dbFromContext(ctx, r.db).Scopes(usageKey(usage)).Limit(1).Find(&usage).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to get spending usage", "error", err.Error())
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (r *spendingCapRepository) AddUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string, amount int64) (bool, error) {
+	usage := entity.SpendingUsage{
+		UserID:    spendingCap.UserID,
+		APIKeyID:  spendingCap.APIKeyID,
+		Period:    spendingCap.Period,
+		PeriodKey: periodKey,
+	}
+	db := dbFromContext(ctx, r.db)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+		r.logger.Error(ctx, "Failed to create spending usage", "error", err.Error())
+		return false, err
+	}
+
+	// The limits are checked by the update itself, so concurrent sends can't
+	// both squeeze under the cap.
+	query := db.Model(&entity.SpendingUsage{}).Scopes(usageKey(usage))
+	if spendingCap.MaxAmount != nil {
+		query = query.Where("amount + ? <= ?", amount, *spendingCap.MaxAmount)
+	}
+	if spendingCap.MaxMessages != nil {
+		query = query.Where("messages + 1 <= ?", *spendingCap.MaxMessages)
+	}
+	result := query.Updates(map[string]any{
+		"amount":   gorm.Expr("amount + ?", amount),
+		"messages": gorm.Expr("messages + 1"),
+	})
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to add spending usage", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *spendingCapRepository) RemoveUsage(ctx context.Context, spendingCap entity.SpendingCap, periodKey string, amount int64) error {
+	usage := entity.SpendingUsage{
+		UserID:    spendingCap.UserID,
+		APIKeyID:  spendingCap.APIKeyID,
+		Period:    spendingCap.Period,
+		PeriodKey: periodKey,
+	}
+	// GREATEST keeps the usage from going below zero, e.g. for a cap set up
+	// after the message was reserved.
+	err := dbFromContext(ctx, r.db).Model(&entity.SpendingUsage{}).
+		Scopes(usageKey(usage)).
+		Updates(map[string]any{
+			"amount":   gorm.Expr("GREATEST(amount, ?) - ?", amount, amount),
+			"messages": gorm.Expr("GREATEST(messages, 1) - 1"),
+		}).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to remove spending usage", "error", err.Error())
+		return err
+	}
+	return nil
+}
+
+// usageKey matches the usage row with the same primary key. Struct conditions
+// would skip the zero api_key_id of account-wide caps.
+func usageKey(usage entity.SpendingUsage) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND api_key_id = ? AND period = ? AND period_key = ?",
+			usage.UserID, usage.APIKeyID, usage.Period, usage.PeriodKey)
+	}
+}
@@ -22,7 +22,7 @@ func NewTransactionRepository(db *gorm.DB, logger *logger.Logger) port.Transacti
 }
 
 func (r *transactionRepository) Create(ctx context.Context, transaction *entity.Transaction) error {
-	err := r.db.WithContext(ctx).Create(transaction).Error
+	err := dbFromContext(ctx, r.db).Create(transaction).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to create transaction", "error", err.Error())
 		return err
@@ -30,9 +30,10 @@ func (r *transactionRepository) Create(ctx context.Context, transaction *entity.
 	return nil
 }
 
+// GetBySMSID returns the debit transaction of the SMS.
 func (r *transactionRepository) GetBySMSID(ctx context.Context, smsID uint64) (*entity.Transaction, error) {
 	var transaction entity.Transaction
-	err := r.db.WithContext(ctx).Where("sms_id = ?", smsID).First(&transaction).Error
+	err := dbFromContext(ctx, r.db).Where("sms_id = ? AND operation = ?", smsID, entity.Decrease).First(&transaction).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to get transaction by sms id", "error", err.Error())
 		return nil, err
@@ -40,11 +41,53 @@ func (r *transactionRepository) GetBySMSID(ctx context.Context, smsID uint64) (*
 	return &transaction, nil
 }
 
+// UpdateStatusBySMSID updates the debit transaction of the SMS; refunds of it
+// are separate transactions that keep their own status.
 func (r *transactionRepository) UpdateStatusBySMSID(ctx context.Context, smsID uint64, status entity.TransactionStatusEnum) error {
-	err := r.db.WithContext(ctx).Model(&entity.Transaction{}).Where("sms_id = ?", smsID).Update("status", status).Error
+	err := dbFromContext(ctx, r.db).Model(&entity.Transaction{}).Where("sms_id = ? AND operation = ?", smsID, entity.Decrease).Update("status", status).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to update transaction status by sms id", "error", err.Error())
 		return err
 	}
 	return nil
 }
+
+// TransitionBySMSID moves the debit transaction of the SMS from one status to
+// another. It reports false when the debit wasn't in the from status.
+func (r *transactionRepository) TransitionBySMSID(ctx context.Context, smsID uint64, from entity.TransactionStatusEnum, to entity.TransactionStatusEnum) (bool, error) {
+	result := dbFromContext(ctx, r.db).Model(&entity.Transaction{}).
+		Where("sms_id = ? AND operation = ? AND status = ?", smsID, entity.Decrease, from).
+		Update("status", to)
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to transition transaction status by sms id", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *transactionRepository) List(ctx context.Context, filter port.TransactionFilter, beforeID uint64, limit int) ([]entity.Transaction, error) {
+	query := dbFromContext(ctx, r.db).Where("user_id = ?", filter.UserID)
+	if beforeID != 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var transactions []entity.Transaction
+	err := query.Order("id DESC").Limit(limit).Find(&transactions).Error
+	if err != nil {
+		r.logger.Error(ctx, "Failed to list transactions", "error", err.Error())
+		return nil, err
+	}
+	return transactions, nil
+}
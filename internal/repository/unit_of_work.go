@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"gorm.io/gorm"
+)
+
+// txKey is the context key the transaction of a unit of work is stored under.
+type txKey struct{}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) port.UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+// Do runs fn in a database transaction. Repository calls made with the
+// context passed to fn join that transaction, which commits when fn returns
+// nil and rolls back otherwise. A Do nested in another one joins the outer
+// transaction.
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// dbFromContext returns the transaction of the unit of work ctx belongs to,
+// or db when there's none.
+func dbFromContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"strings"
 
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
@@ -23,7 +24,7 @@ func NewUserRepository(db *gorm.DB, logger *logger.Logger) port.UserRepository {
 
 func (r *userRepository) GetByID(ctx context.Context, id uint64) (*entity.User, error) {
 	var user entity.User
-	err := r.db.WithContext(ctx).First(&user, id).Error
+	err := dbFromContext(ctx, r.db).First(&user, id).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to get user by id", "error", err.Error())
 		return nil, err
@@ -32,7 +33,7 @@ func (r *userRepository) GetByID(ctx context.Context, id uint64) (*entity.User,
 }
 
 func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity.User, error) {
-	err := r.db.WithContext(ctx).Create(user).Error
+	err := dbFromContext(ctx, r.db).Create(user).Error
 	if err != nil {
 		r.logger.Error(ctx, "Failed to create user", "error", err.Error())
 		return nil, err
@@ -41,29 +42,69 @@ func (r *userRepository) Create(ctx context.Context, user *entity.User) (*entity
 	return user, nil
 }
 
-func (r *userRepository) HasEnoughCredit(ctx context.Context, userID uint64, amount uint32) (bool, error) {
-	var user entity.User
-	err := r.db.WithContext(ctx).Select("credit").First(&user, userID).Error
+// likeEscaper escapes the wildcards of a LIKE pattern, and MySQL's default
+// escape character itself, so a search term matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *userRepository) Search(ctx context.Context, filter port.UserFilter, limit int, offset int) ([]entity.User, int64, error) {
+	query := dbFromContext(ctx, r.db).Model(&entity.User{})
+	if filter.Query != "" {
+		like := "%" + likeEscaper.Replace(filter.Query) + "%"
+		query = query.Where("name LIKE ? OR phone_number LIKE ?", like, like)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.Error(ctx, "Failed to count users", "error", err.Error())
+		return nil, 0, err
+	}
+
+	var users []entity.User
+	err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&users).Error
 	if err != nil {
-		return false, err
+		r.logger.Error(ctx, "Failed to search users", "error", err.Error())
+		return nil, 0, err
 	}
-	return user.Credit >= int64(amount), nil
+	return users, total, nil
 }
 
-func (r *userRepository) IncreaseCredit(ctx context.Context, user *entity.User, amount uint32) error {
-	err := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", user.ID).Update("credit", gorm.Expr("credit + ?", amount)).Error
+func (r *userRepository) Update(ctx context.Context, userID uint64, fields map[string]any) error {
+	err := dbFromContext(ctx, r.db).Model(&entity.User{}).Where("id = ?", userID).Updates(fields).Error
 	if err != nil {
-		r.logger.Error(ctx, "Failed to increase credit", "error", err.Error())
+		r.logger.Error(ctx, "Failed to update user", "error", err.Error())
 		return err
 	}
 	return nil
 }
 
-func (r *userRepository) DecreaseCredit(ctx context.Context, user *entity.User, amount uint32) error {
-	err := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", user.ID).Update("credit", gorm.Expr("credit - ?", amount)).Error
+func (r *userRepository) UpdateBilling(ctx context.Context, userID uint64, accountType entity.AccountTypeEnum, creditLimit int64) (bool, error) {
+	query := dbFromContext(ctx, r.db).Model(&entity.User{}).Where("id = ?", userID)
+	if accountType == entity.AccountTypePrepaid {
+		query = query.Where("credit >= 0")
+	}
+	result := query.Updates(map[string]any{
+		"account_type": accountType,
+		"credit_limit": creditLimit,
+	})
+	if result.Error != nil {
+		r.logger.Error(ctx, "Failed to update user billing", "error", result.Error.Error())
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *userRepository) ListOutstanding(ctx context.Context) ([]entity.User, error) {
+	var users []entity.User
+	err := dbFromContext(ctx, r.db).
+		Where("account_type = ? AND credit < 0", entity.AccountTypePostpaid).
+		Order("credit ASC").
+		Find(&users).Error
 	if err != nil {
-		r.logger.Error(ctx, "Failed to decrease credit", "error", err.Error())
-		return err
+		r.logger.Error(ctx, "Failed to list outstanding balances", "error", err.Error())
+		return nil, err
 	}
-	return nil
+	return users, nil
 }
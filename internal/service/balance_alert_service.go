@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const WebhookEventBalanceLow = "balance.low"
+
+type balanceAlertService struct {
+	alertRepo       port.BalanceAlertRepository
+	userRepo        port.UserRepository
+	smsRepo         port.SMSRepository
+	outboxRepo      port.OutboxRepository
+	unitOfWork      port.UnitOfWork
+	statusPublisher port.SMSStatusPublisher
+	webhook         port.WebhookSender
+	logger          *logger.Logger
+	config          config.AlertConfig
+
+	// inflight tracks alerts being sent in the background.
+	inflight sync.WaitGroup
+}
+
+// NewBalanceAlertService returns the low-balance alert service. Alert SMS are
+// put in the outbox directly rather than sent through the SMS service:
+// they're free, and the SMS service itself calls Check after every debit.
+func NewBalanceAlertService(
+	alertRepo port.BalanceAlertRepository,
+	userRepo port.UserRepository,
+	smsRepo port.SMSRepository,
+	outboxRepo port.OutboxRepository,
+	unitOfWork port.UnitOfWork,
+	statusPublisher port.SMSStatusPublisher,
+	webhook port.WebhookSender,
+	logger *logger.Logger,
+	config config.AlertConfig,
+) port.BalanceAlertService {
+	return &balanceAlertService{
+		alertRepo:       alertRepo,
+		userRepo:        userRepo,
+		smsRepo:         smsRepo,
+		outboxRepo:      outboxRepo,
+		unitOfWork:      unitOfWork,
+		statusPublisher: statusPublisher,
+		webhook:         webhook,
+		logger:          logger,
+		config:          config,
+	}
+}
+
+func (s *balanceAlertService) GetSettings(ctx context.Context, userID uint64) (*entity.BalanceAlertSetting, error) {
+	setting, err := s.alertRepo.GetSettings(ctx, userID)
+	if stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return &entity.BalanceAlertSetting{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	return setting, nil
+}
+
+func (s *balanceAlertService) UpdateSettings(ctx context.Context, setting *entity.BalanceAlertSetting) (*entity.BalanceAlertSetting, error) {
+	if setting.Enabled && !setting.NotifySMS && !setting.NotifyWebhook {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "At least one notification channel must be selected")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, setting.UserID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	if setting.NotifyWebhook && user.WebhookURL == "" {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Set a webhook before enabling webhook alerts")
+	}
+	if setting.NotifySMS && user.PhoneNumber == "" {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Set a phone number before enabling SMS alerts")
+	}
+
+	if err := s.alertRepo.SaveSettings(ctx, setting); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	return s.GetSettings(ctx, setting.UserID)
+}
+
+func (s *balanceAlertService) ListAlerts(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.BalanceAlert, int64, error) {
+	return s.alertRepo.ListAlerts(ctx, userID, pageSize, (page-1)*pageSize)
+}
+
+func (s *balanceAlertService) Check(ctx context.Context, userID uint64, balance int64) {
+	// Claiming sets the user's last alert time, so of several concurrent
+	// debits crossing the threshold only one sends an alert.
+	setting, err := s.alertRepo.Claim(ctx, userID, balance, time.Now().Add(-s.config.Cooldown))
+	if err != nil || setting == nil {
+		return
+	}
+
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		s.send(context.WithoutCancel(ctx), setting, balance)
+	}()
+}
+
+func (s *balanceAlertService) Wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.logger.Warn(ctx, "Gave up waiting for balance alerts in flight")
+	}
+}
+
+func (s *balanceAlertService) send(ctx context.Context, setting *entity.BalanceAlertSetting, balance int64) {
+	user, err := s.userRepo.GetByID(ctx, setting.UserID)
+	if err != nil {
+		s.logger.Error(ctx, "Failed to load user for balance alert", "error", err.Error(), "user_id", setting.UserID)
+		return
+	}
+
+	alert := &entity.BalanceAlert{
+		UserID:    uint64(user.ID),
+		Threshold: setting.Threshold,
+		Balance:   balance,
+	}
+
+	if setting.NotifySMS && user.PhoneNumber != "" {
+		sms, err := s.sendSMS(ctx, user, setting.Threshold, balance)
+		if err != nil {
+			s.logger.Error(ctx, "Failed to send balance alert sms", "error", err.Error(), "user_id", user.ID)
+		}
+		if sms != nil {
+			alert.SMSID = &sms.ID
+		}
+	}
+
+	if setting.NotifyWebhook {
+		status := entity.BalanceAlertWebhookSkipped
+		if user.WebhookURL != "" {
+			status = entity.BalanceAlertWebhookSent
+			if err := s.sendWebhook(ctx, user, setting.Threshold, balance); err != nil {
+				s.logger.Warn(ctx, "Failed to send balance alert webhook", "error", err.Error(), "user_id", user.ID)
+				status = entity.BalanceAlertWebhookFailed
+				alert.WebhookError = truncate(err.Error(), maxErrorLength)
+			}
+		}
+		alert.WebhookStatus = &status
+	}
+
+	if err := s.alertRepo.CreateAlert(ctx, alert); err != nil {
+		s.logger.Error(ctx, "Failed to record balance alert", "error", err.Error(), "user_id", user.ID)
+	}
+}
+
+// sendSMS queues the alert to the user's phone number as a free, high
+// priority system message.
+func (s *balanceAlertService) sendSMS(ctx context.Context, user *entity.User, threshold int64, balance int64) (*entity.SMS, error) {
+	message := strings.NewReplacer(
+		"{balance}", strconv.FormatInt(balance, 10),
+		"{threshold}", strconv.FormatInt(threshold, 10),
+	).Replace(s.config.SMSTemplate)
+
+	sms := &entity.SMS{
+		UserID:        uint64(user.ID),
+		ReceiveNumber: user.PhoneNumber,
+		Message:       message,
+		Status:        entity.SMSStatusPending,
+		Priority:      entity.SMSPriorityHigh,
+	}
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.smsRepo.Create(ctx, sms); err != nil {
+			return err
+		}
+		return s.outboxRepo.Create(ctx, &entity.OutboxMessage{
+			SMSID:    sms.ID,
+			UserID:   sms.UserID,
+			Priority: sms.Priority,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.statusPublisher != nil {
+		if err := s.statusPublisher.PublishStatus(ctx, sms); err != nil {
+			s.logger.Error(ctx, "Failed to publish sms status event", "error", err.Error(), "sms_id", sms.ID)
+		}
+	}
+	return sms, nil
+}
+
+func (s *balanceAlertService) sendWebhook(ctx context.Context, user *entity.User, threshold int64, balance int64) error {
+	return s.webhook.Send(ctx, user, WebhookEventBalanceLow, map[string]any{
+		"user_id":   user.ID,
+		"balance":   balance,
+		"threshold": threshold,
+	})
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deadLetterScanLimit caps how many messages of a dead-letter queue one
+// operation looks at, as they are all held unacked until it's done.
+const deadLetterScanLimit = 10000
+
+type deadLetterService struct {
+	rabbitMQConnection *connection.RabbitMQConnection
+	queueStrategy      *QueueDistributionStrategy
+	logger             *logger.Logger
+}
+
+func NewDeadLetterService(
+	rabbitMQConnection *connection.RabbitMQConnection,
+	queueStrategy *QueueDistributionStrategy,
+	logger *logger.Logger,
+) port.DeadLetterService {
+	return &deadLetterService{
+		rabbitMQConnection: rabbitMQConnection,
+		queueStrategy:      queueStrategy,
+		logger:             logger,
+	}
+}
+
+// List peeks at the dead-letter queues. The messages are never acked, so
+// closing the channel puts them back.
+func (s *deadLetterService) List(ctx context.Context, queue string, limit int) ([]port.DeadLetterMessage, error) {
+	queueNames, err := s.queueNames(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]port.DeadLetterMessage, 0)
+	err = s.rabbitMQConnection.WithChannel(func(channel *amqp.Channel) error {
+		for _, queueName := range queueNames {
+			for len(messages) < limit {
+				delivery, ok, err := channel.Get(connection.DeadLetterQueueName(queueName), false)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+				messages = append(messages, deadLetterMessage(queueName, delivery))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error(ctx, "Failed to list dead-lettered messages", "error", err.Error())
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *deadLetterService) Replay(ctx context.Context, queue string, messageID string) (int, error) {
+	return s.drain(ctx, queue, messageID, func(channel *connection.ConfirmedChannel, queueName string, delivery amqp.Delivery) error {
+		headers := amqp.Table{}
+		for key, value := range delivery.Headers {
+			headers[key] = value
+		}
+		delete(headers, connection.HeaderOriginalQueue)
+		delete(headers, connection.HeaderDeadLetterReason)
+
+		// The dead-lettered copy is only acked once the broker confirmed the
+		// replayed one, so a lost publish can't lose the message.
+		return channel.Publish(ctx, "", queueName, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  delivery.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    delivery.MessageId,
+			Timestamp:    time.Now(),
+			Body:         delivery.Body,
+		})
+	})
+}
+
+func (s *deadLetterService) Purge(ctx context.Context, queue string, messageID string) (int, error) {
+	if messageID == "" {
+		queueNames, err := s.queueNames(queue)
+		if err != nil {
+			return 0, err
+		}
+
+		purged := 0
+		err = s.rabbitMQConnection.WithChannel(func(channel *amqp.Channel) error {
+			for _, queueName := range queueNames {
+				count, err := channel.QueuePurge(connection.DeadLetterQueueName(queueName), false)
+				if err != nil {
+					return err
+				}
+				purged += count
+			}
+			return nil
+		})
+		if err != nil {
+			s.logger.Error(ctx, "Failed to purge dead-letter queues", "error", err.Error())
+			return purged, err
+		}
+		return purged, nil
+	}
+
+	return s.drain(ctx, queue, messageID, func(*connection.ConfirmedChannel, string, amqp.Delivery) error {
+		return nil
+	})
+}
+
+// drain hands the dead-lettered messages to fn, along with the queue they came
+// from, and acks each one fn handled. With a messageID only that message is
+// handled and the others go back to their queue.
+//
+// Only the messages a dead-letter queue held when drain got to it are looked
+// at. A replayed message that fails again is dead-lettered anew behind them,
+// and is left for the next replay rather than picked up again.
+func (s *deadLetterService) drain(
+	ctx context.Context,
+	queue string,
+	messageID string,
+	fn func(channel *connection.ConfirmedChannel, queueName string, delivery amqp.Delivery) error,
+) (int, error) {
+	queueNames, err := s.queueNames(queue)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	err = s.rabbitMQConnection.WithConfirmedChannel(func(channel *connection.ConfirmedChannel) error {
+		for _, queueName := range queueNames {
+			deadLetterQueue, err := channel.QueueDeclarePassive(connection.DeadLetterQueueName(queueName), true, false, false, false, nil)
+			if err != nil {
+				return err
+			}
+
+			waiting := min(deadLetterQueue.Messages, deadLetterScanLimit)
+			for scanned := 0; scanned < waiting; scanned++ {
+				delivery, ok, err := channel.Get(deadLetterQueue.Name, false)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+				if messageID != "" && delivery.MessageId != messageID {
+					continue
+				}
+
+				if err := fn(channel, queueName, delivery); err != nil {
+					return err
+				}
+				if err := delivery.Ack(false); err != nil {
+					return err
+				}
+				handled++
+
+				if messageID != "" {
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error(ctx, "Failed to handle dead-lettered messages", "error", err.Error(), "message_id", messageID)
+		return handled, err
+	}
+
+	if messageID != "" && handled == 0 {
+		return 0, errors.ErrDeadLetterNotFound
+	}
+	return handled, nil
+}
+
+// queueNames returns the SMS queue whose dead-letter queue is meant, or all of
+// them when queue is empty.
+func (s *deadLetterService) queueNames(queue string) ([]string, error) {
+	queueNames := s.queueStrategy.GetQueueNames()
+	if queue == "" {
+		return queueNames, nil
+	}
+	if !slices.Contains(queueNames, queue) {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, fmt.Sprintf("Unknown queue %q", queue))
+	}
+	return []string{queue}, nil
+}
+
+func deadLetterMessage(queueName string, delivery amqp.Delivery) port.DeadLetterMessage {
+	reason, _ := delivery.Headers[connection.HeaderDeadLetterReason].(string)
+	return port.DeadLetterMessage{
+		ID:             delivery.MessageId,
+		Queue:          queueName,
+		Reason:         reason,
+		Body:           string(delivery.Body),
+		Attempts:       connection.DeliveryAttempts(delivery),
+		DeadLetteredAt: delivery.Timestamp,
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+// ExpirySweeper expires and refunds pending messages whose validity period has
+// passed without a consumer seeing them, e.g. because the broker discarded
+// them. Expiring is conditional on the message still being pending, so
+// instances can sweep side by side.
+type ExpirySweeper struct {
+	smsRepo    port.SMSRepository
+	smsService port.SMSService
+	logger     *logger.Logger
+	config     config.ExpiryConfig
+}
+
+func NewExpirySweeper(
+	smsRepo port.SMSRepository,
+	smsService port.SMSService,
+	logger *logger.Logger,
+	config config.ExpiryConfig,
+) *ExpirySweeper {
+	return &ExpirySweeper{
+		smsRepo:    smsRepo,
+		smsService: smsService,
+		logger:     logger,
+		config:     config,
+	}
+}
+
+// Run sweeps every SweepInterval until ctx is cancelled.
+func (e *ExpirySweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.sweep(ctx)
+		}
+	}
+}
+
+func (e *ExpirySweeper) sweep(ctx context.Context) {
+	smsList, err := e.smsRepo.ListExpiredPending(ctx, time.Now().Add(-e.config.SweepGrace), e.config.SweepBatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, sms := range smsList {
+		expired, err := e.smsService.ExpireSMS(ctx, sms.ID)
+		if err != nil {
+			continue
+		}
+		if expired {
+			e.logger.Info(ctx, "Expired stale sms", "sms_id", sms.ID, "expires_at", sms.ExpiresAt)
+		}
+	}
+}
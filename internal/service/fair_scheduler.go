@@ -0,0 +1,132 @@
+package service
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+)
+
+// fairScheduler shares the relay's publishing budget between users by deficit
+// round robin. Users take turns in order of id; each turn a user earns
+// quantum times their plan's weight in credit and may publish that many
+// messages. However large one user's backlog, every other user with messages
+// waiting gets a turn before that user's next one, so a small sender waits at
+// most one round behind a blast.
+//
+// It only decides who publishes how much, the publishing itself is left to a
+// callback, so it runs without a database or broker.
+type fairScheduler struct {
+	quantum int
+	weights map[string]int
+
+	// deficits is the credit left in each user's current turn, and last the
+	// user who had the last turn. A turn cut short by the budget resumes in
+	// the next pass.
+	deficits map[uint64]int
+	last     uint64
+}
+
+func newFairScheduler(quantum int, weights map[string]int) *fairScheduler {
+	return &fairScheduler{
+		quantum:  max(quantum, 1),
+		weights:  weights,
+		deficits: make(map[uint64]int),
+	}
+}
+
+// publishTurn publishes up to allowance messages of a user and returns how
+// many it published and whether that emptied the user's backlog.
+type publishTurn func(userID uint64, allowance int) (published int, drained bool, err error)
+
+// schedule hands out up to budget messages between tenants, who must be
+// ordered by id, and returns how many were published. It stops at the first
+// error of publish.
+func (s *fairScheduler) schedule(tenants []port.OutboxTenant, budget int, publish publishTurn) (int, error) {
+	waiting := make(map[uint64]bool, len(tenants))
+	for _, tenant := range tenants {
+		waiting[tenant.UserID] = true
+	}
+	// Users who ran out of messages start their next turn from scratch.
+	for userID := range s.deficits {
+		if !waiting[userID] {
+			delete(s.deficits, userID)
+		}
+	}
+
+	active := tenants
+	published := 0
+	for budget > 0 && len(active) > 0 {
+		start := s.startIndex(active)
+		progressed := false
+		next := make([]port.OutboxTenant, 0, len(active))
+
+		for k := range active {
+			tenant := active[(start+k)%len(active)]
+			if budget <= 0 {
+				next = append(next, tenant)
+				continue
+			}
+
+			if s.deficits[tenant.UserID] <= 0 {
+				s.deficits[tenant.UserID] = s.quantum * s.weight(tenant.Plan)
+			}
+			allowance := min(s.deficits[tenant.UserID], budget)
+
+			sent, drained, err := publish(tenant.UserID, allowance)
+			published += sent
+			budget -= sent
+			s.deficits[tenant.UserID] -= sent
+			s.last = tenant.UserID
+			if err != nil {
+				return published, err
+			}
+
+			if sent > 0 {
+				progressed = true
+			}
+			if drained {
+				delete(s.deficits, tenant.UserID)
+				continue
+			}
+			next = append(next, tenant)
+		}
+
+		if !progressed {
+			break
+		}
+		active = sortedByUser(next)
+	}
+	return published, nil
+}
+
+// startIndex returns where the next round starts: with the last user when
+// their turn was cut short, with the user after them otherwise.
+func (s *fairScheduler) startIndex(tenants []port.OutboxTenant) int {
+	for i, tenant := range tenants {
+		if tenant.UserID == s.last && s.deficits[tenant.UserID] > 0 {
+			return i
+		}
+	}
+	for i, tenant := range tenants {
+		if tenant.UserID > s.last {
+			return i
+		}
+	}
+	return 0
+}
+
+func (s *fairScheduler) weight(plan string) int {
+	if weight, ok := s.weights[plan]; ok {
+		return weight
+	}
+	return 1
+}
+
+// sortedByUser restores id order after a round that started mid-list.
+func sortedByUser(tenants []port.OutboxTenant) []port.OutboxTenant {
+	slices.SortFunc(tenants, func(a, b port.OutboxTenant) int {
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return tenants
+}
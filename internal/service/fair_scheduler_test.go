@@ -0,0 +1,268 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+)
+
+// turn is one call the scheduler made to publish a user's messages.
+type turn struct {
+	userID uint64
+	sent   int
+}
+
+// outboxBacklog fakes the messages users have waiting. publish drains a
+// user's backlog the way the relay's callback does and records the turn.
+type outboxBacklog struct {
+	waiting map[uint64]int
+	turns   []turn
+}
+
+func (b *outboxBacklog) publish(userID uint64, allowance int) (int, bool, error) {
+	sent := min(allowance, b.waiting[userID])
+	b.waiting[userID] -= sent
+	b.turns = append(b.turns, turn{userID: userID, sent: sent})
+	return sent, b.waiting[userID] == 0, nil
+}
+
+// tenants lists the users with messages waiting, in order of id.
+func (b *outboxBacklog) tenants(plans map[uint64]string) []port.OutboxTenant {
+	var tenants []port.OutboxTenant
+	for userID, waiting := range b.waiting {
+		if waiting > 0 {
+			tenants = append(tenants, port.OutboxTenant{UserID: userID, Plan: plans[userID]})
+		}
+	}
+	return sortedByUser(tenants)
+}
+
+func (b *outboxBacklog) sentBy(userID uint64) int {
+	sent := 0
+	for _, t := range b.turns {
+		if t.userID == userID {
+			sent += t.sent
+		}
+	}
+	return sent
+}
+
+func TestFairSchedulerServesSmallSenderDuringBlast(t *testing.T) {
+	const (
+		blaster     = 1
+		smallSender = 2
+		quantum     = 10
+	)
+
+	tests := []struct {
+		name   string
+		budget int
+		// maxPasses is how many passes may go by before the small sender has
+		// been served completely.
+		maxPasses int
+	}{
+		{name: "budget covers a round", budget: 100, maxPasses: 1},
+		{name: "budget covers one turn", budget: quantum, maxPasses: 2},
+		{name: "budget smaller than a turn", budget: 3, maxPasses: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := newFairScheduler(quantum, nil)
+			backlog := &outboxBacklog{waiting: map[uint64]int{blaster: 100000, smallSender: 3}}
+
+			// The blast is already underway when the small sender shows up.
+			if _, err := scheduler.schedule(backlog.tenants(nil)[:1], tt.budget, backlog.publish); err != nil {
+				t.Fatalf("schedule: %v", err)
+			}
+
+			arrived := len(backlog.turns)
+			passes := 0
+			for backlog.waiting[smallSender] > 0 {
+				passes++
+				if passes > tt.maxPasses {
+					t.Fatalf("small sender still has %d messages waiting after %d passes", backlog.waiting[smallSender], tt.maxPasses)
+				}
+				if _, err := scheduler.schedule(backlog.tenants(nil), tt.budget, backlog.publish); err != nil {
+					t.Fatalf("schedule: %v", err)
+				}
+			}
+
+			// Once the small sender showed up, the blaster got at most the
+			// rest of one turn before the small sender was done.
+			blasted := 0
+			for _, turn := range backlog.turns[arrived:] {
+				if turn.userID == smallSender && backlog.waiting[smallSender] == 0 {
+					break
+				}
+				if turn.userID == blaster {
+					blasted += turn.sent
+				}
+			}
+			if blasted > quantum {
+				t.Errorf("blaster published %d messages while the small sender waited, want at most %d", blasted, quantum)
+			}
+		})
+	}
+}
+
+func TestFairSchedulerRoundRobin(t *testing.T) {
+	scheduler := newFairScheduler(5, nil)
+	backlog := &outboxBacklog{waiting: map[uint64]int{1: 1000, 2: 1000, 3: 1000}}
+
+	for range 4 {
+		if _, err := scheduler.schedule(backlog.tenants(nil), 5, backlog.publish); err != nil {
+			t.Fatalf("schedule: %v", err)
+		}
+	}
+
+	var order []uint64
+	for _, turn := range backlog.turns {
+		order = append(order, turn.userID)
+	}
+	if want := []uint64{1, 2, 3, 1}; !slices.Equal(order, want) {
+		t.Errorf("turns = %v, want %v", order, want)
+	}
+}
+
+func TestFairSchedulerResumesTurnCutShort(t *testing.T) {
+	scheduler := newFairScheduler(10, nil)
+	backlog := &outboxBacklog{waiting: map[uint64]int{1: 1000, 2: 1000}}
+
+	// The first pass can only publish 4 of user 1's 10.
+	if _, err := scheduler.schedule(backlog.tenants(nil), 4, backlog.publish); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if _, err := scheduler.schedule(backlog.tenants(nil), 100, backlog.publish); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+
+	want := []turn{{1, 4}, {1, 6}, {2, 10}}
+	if got := backlog.turns[:3]; !slices.Equal(got, want) {
+		t.Errorf("turns = %v, want %v", got, want)
+	}
+}
+
+func TestFairSchedulerPlanWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]int
+		plans   map[uint64]string
+		// want is each user's share of what was published.
+		want map[uint64]int
+	}{
+		{
+			name:  "no weights",
+			plans: map[uint64]string{1: "basic", 2: "premium"},
+			want:  map[uint64]int{1: 500, 2: 500},
+		},
+		{
+			name:    "premium weighs four",
+			weights: map[string]int{"basic": 1, "premium": 4},
+			plans:   map[uint64]string{1: "basic", 2: "premium"},
+			want:    map[uint64]int{1: 200, 2: 800},
+		},
+		{
+			name:    "unlisted plan weighs one",
+			weights: map[string]int{"premium": 3},
+			plans:   map[uint64]string{1: "enterprise", 2: "premium", 3: "basic"},
+			want:    map[uint64]int{1: 200, 2: 600, 3: 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := newFairScheduler(10, tt.weights)
+			backlog := &outboxBacklog{waiting: make(map[uint64]int)}
+			for userID := range tt.plans {
+				backlog.waiting[userID] = 100000
+			}
+
+			published, err := scheduler.schedule(backlog.tenants(tt.plans), 1000, backlog.publish)
+			if err != nil {
+				t.Fatalf("schedule: %v", err)
+			}
+			if published != 1000 {
+				t.Errorf("published = %d, want 1000", published)
+			}
+			for userID, want := range tt.want {
+				if got := backlog.sentBy(userID); got != want {
+					t.Errorf("user %d published %d, want %d", userID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFairSchedulerSkipsDrainedUsers(t *testing.T) {
+	scheduler := newFairScheduler(10, nil)
+	backlog := &outboxBacklog{waiting: map[uint64]int{1: 2, 2: 1000}}
+
+	published, err := scheduler.schedule(backlog.tenants(nil), 30, backlog.publish)
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if published != 30 {
+		t.Errorf("published = %d, want 30", published)
+	}
+	if got := backlog.sentBy(2); got != 28 {
+		t.Errorf("user 2 published %d, want 28", got)
+	}
+}
+
+// pagedOutbox serves ListDueTenants from a backlog, like the repository.
+type pagedOutbox struct {
+	port.OutboxRepository
+	backlog *outboxBacklog
+}
+
+func (o *pagedOutbox) ListDueTenants(ctx context.Context, fromUserID uint64, limit int) ([]port.OutboxTenant, error) {
+	var tenants []port.OutboxTenant
+	for _, tenant := range o.backlog.tenants(nil) {
+		if tenant.UserID >= fromUserID && len(tenants) < limit {
+			tenants = append(tenants, tenant)
+		}
+	}
+	return tenants, nil
+}
+
+func TestOutboxRelayPagesThroughTenants(t *testing.T) {
+	const users = outboxMaxTenants*2 + 500
+
+	backlog := &outboxBacklog{waiting: make(map[uint64]int)}
+	for userID := uint64(1); userID <= users; userID++ {
+		backlog.waiting[userID] = 100
+	}
+	relay := &OutboxRelay{
+		outboxRepo: &pagedOutbox{backlog: backlog},
+		scheduler:  newFairScheduler(1, nil),
+	}
+
+	// Each pass can serve a fraction of the users, yet within a few passes
+	// every one of them, high ids included, has had a turn.
+	ctx := context.Background()
+	for range 6 {
+		tenants, err := relay.dueTenants(ctx)
+		if err != nil {
+			t.Fatalf("dueTenants: %v", err)
+		}
+		if len(tenants) > outboxMaxTenants {
+			t.Fatalf("dueTenants returned %d users, want at most %d", len(tenants), outboxMaxTenants)
+		}
+		if !slices.IsSortedFunc(tenants, func(a, b port.OutboxTenant) int { return cmp.Compare(a.UserID, b.UserID) }) {
+			t.Fatalf("dueTenants returned users out of order")
+		}
+		if _, err := relay.scheduler.schedule(tenants, 500, backlog.publish); err != nil {
+			t.Fatalf("schedule: %v", err)
+		}
+	}
+
+	for userID := uint64(1); userID <= users; userID++ {
+		if backlog.sentBy(userID) == 0 {
+			t.Fatalf("user %d never had a turn", userID)
+		}
+	}
+}
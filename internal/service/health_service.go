@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+)
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+type healthService struct {
+	checks       []healthCheck
+	config       config.HealthConfig
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(
+	sqlDB *sql.DB,
+	rabbitMQConnection *connection.RabbitMQConnection,
+	consumer *MultiQueueConsumer,
+	provider port.Provider,
+	config config.HealthConfig,
+) port.HealthService {
+	checks := []healthCheck{
+		{name: "mysql", check: sqlDB.PingContext},
+		{name: "rabbitmq", check: func(ctx context.Context) error {
+			if !rabbitMQConnection.IsConnected() {
+				return errors.New("rabbitmq connection or channel is closed")
+			}
+			return nil
+		}},
+		{name: "consumers", check: consumer.CheckConsumers},
+	}
+	if config.CheckProvider {
+		checks = append(checks, healthCheck{name: "provider", check: provider.Ping})
+	}
+
+	return &healthService{
+		checks: checks,
+		config: config,
+	}
+}
+
+func (s *healthService) Liveness(ctx context.Context) port.HealthReport {
+	return port.HealthReport{Status: port.HealthStatusOK}
+}
+
+// Readiness runs all dependency checks concurrently, each bounded by the
+// configured timeout. It reports unavailable as soon as shutdown started.
+func (s *healthService) Readiness(ctx context.Context) port.HealthReport {
+	report := port.HealthReport{
+		Status: port.HealthStatusOK,
+		Checks: make(map[string]port.HealthCheckResult, len(s.checks)+1),
+	}
+
+	if s.shuttingDown.Load() {
+		report.Status = port.HealthStatusUnavailable
+		report.Checks["shutdown"] = port.HealthCheckResult{
+			Status: port.HealthStatusUnavailable,
+			Error:  "service is shutting down",
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, hc := range s.checks {
+		wg.Add(1)
+		go func(hc healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, s.config.CheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := hc.check(checkCtx)
+			result := port.HealthCheckResult{
+				Status:    port.HealthStatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = port.HealthStatusUnavailable
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[hc.name] = result
+			if err != nil {
+				report.Status = port.HealthStatusUnavailable
+			}
+		}(hc)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (s *healthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
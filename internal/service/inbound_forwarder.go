@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+const (
+	WebhookEventInboundMessage = "inbound.message"
+
+	inboundForwardMaxRetryDelay = time.Hour
+)
+
+// InboundForwarder forwards pending inbound messages to their owner's webhook,
+// retrying failed deliveries with exponential backoff. Messages are leased
+// before forwarding, so several instances can run it side by side.
+type InboundForwarder struct {
+	messageRepo port.InboundMessageRepository
+	userRepo    port.UserRepository
+	webhook     port.WebhookSender
+	logger      *logger.Logger
+	config      config.InboundConfig
+}
+
+func NewInboundForwarder(
+	messageRepo port.InboundMessageRepository,
+	userRepo port.UserRepository,
+	webhook port.WebhookSender,
+	logger *logger.Logger,
+	config config.InboundConfig,
+) *InboundForwarder {
+	return &InboundForwarder{
+		messageRepo: messageRepo,
+		userRepo:    userRepo,
+		webhook:     webhook,
+		logger:      logger,
+		config:      config,
+	}
+}
+
+// Run forwards due messages every ForwardInterval until ctx is cancelled.
+func (f *InboundForwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.config.ForwardInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.forwardDue(ctx)
+		}
+	}
+}
+
+func (f *InboundForwarder) forwardDue(ctx context.Context) {
+	messages, err := f.messageRepo.ListDueForForward(ctx, f.config.ForwardBatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			return
+		}
+
+		claimed, err := f.messageRepo.ClaimForward(ctx, message.ID, time.Now().Add(2*f.config.ForwardTimeout))
+		if err != nil || !claimed {
+			continue
+		}
+		f.forward(ctx, message)
+	}
+}
+
+func (f *InboundForwarder) forward(ctx context.Context, message entity.InboundMessage) {
+	if message.UserID == nil {
+		f.record(ctx, message.ID, entity.InboundForwardSkipped, "", nil)
+		return
+	}
+
+	user, err := f.userRepo.GetByID(ctx, *message.UserID)
+	if err != nil {
+		f.logger.Error(ctx, "Failed to load owner of inbound message", "error", err.Error(), "inbound_message_id", message.ID)
+		f.retryOrFail(ctx, message, err)
+		return
+	}
+	if user.WebhookURL == "" {
+		f.record(ctx, message.ID, entity.InboundForwardSkipped, "", nil)
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, f.config.ForwardTimeout)
+	defer cancel()
+
+	if err := f.webhook.Send(sendCtx, user, WebhookEventInboundMessage, message); err != nil {
+		f.logger.Warn(ctx, "Failed to forward inbound message", "error", err.Error(), "inbound_message_id", message.ID, "attempt", message.ForwardAttempts+1)
+		f.retryOrFail(ctx, message, err)
+		return
+	}
+
+	f.record(ctx, message.ID, entity.InboundForwardDelivered, "", nil)
+}
+
+// retryOrFail schedules the next attempt, or gives up once the message has
+// used up its attempts.
+func (f *InboundForwarder) retryOrFail(ctx context.Context, message entity.InboundMessage, cause error) {
+	attempts := message.ForwardAttempts + 1
+	if attempts >= f.config.ForwardMaxAttempts {
+		f.record(ctx, message.ID, entity.InboundForwardFailed, cause.Error(), nil)
+		return
+	}
+
+	delay := f.config.ForwardRetryDelay << (attempts - 1)
+	if delay <= 0 || delay > inboundForwardMaxRetryDelay {
+		delay = inboundForwardMaxRetryDelay
+	}
+	next := time.Now().Add(delay)
+	f.record(ctx, message.ID, entity.InboundForwardPending, cause.Error(), &next)
+}
+
+func (f *InboundForwarder) record(ctx context.Context, messageID uint64, status entity.InboundForwardStatusEnum, forwardError string, next *time.Time) {
+	forwardError = truncate(forwardError, maxErrorLength)
+	// Errors are logged by the repository; the lease expires and the message
+	// is picked up again.
+	_ = f.messageRepo.RecordForwardAttempt(ctx, messageID, status, forwardError, next)
+}
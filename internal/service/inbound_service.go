@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const inboundKeywordMaxLength = 32
+
+type inboundService struct {
+	messageRepo port.InboundMessageRepository
+	routeRepo   port.InboundRouteRepository
+	userRepo    port.UserRepository
+	logger      *logger.Logger
+}
+
+func NewInboundService(
+	messageRepo port.InboundMessageRepository,
+	routeRepo port.InboundRouteRepository,
+	userRepo port.UserRepository,
+	logger *logger.Logger,
+) port.InboundService {
+	return &inboundService{
+		messageRepo: messageRepo,
+		routeRepo:   routeRepo,
+		userRepo:    userRepo,
+		logger:      logger,
+	}
+}
+
+// Receive stores an inbound message and assigns it to the user owning the
+// destination number or keyword. Providers may report a message more than
+// once, so a message already stored is returned as is.
+func (s *inboundService) Receive(ctx context.Context, input port.InboundMessageInput) (*entity.InboundMessage, error) {
+	existing, err := s.messageRepo.GetByProviderMessageID(ctx, input.Provider, input.ProviderMessageID)
+	if err == nil {
+		return existing, nil
+	}
+	if !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if input.ReceivedAt.IsZero() {
+		input.ReceivedAt = time.Now()
+	}
+
+	message := &entity.InboundMessage{
+		Provider:          input.Provider,
+		ProviderMessageID: input.ProviderMessageID,
+		FromNumber:        input.FromNumber,
+		ToNumber:          input.ToNumber,
+		Keyword:           inboundKeyword(input.Message),
+		Message:           input.Message,
+		ForwardStatus:     entity.InboundForwardSkipped,
+		ReceivedAt:        input.ReceivedAt,
+	}
+
+	route, err := s.resolveRoute(ctx, message.ToNumber, message.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	if route != nil {
+		message.UserID = &route.UserID
+		user, err := s.userRepo.GetByID(ctx, route.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user.WebhookURL != "" {
+			message.ForwardStatus = entity.InboundForwardPending
+		}
+	} else {
+		s.logger.Warn(ctx, "No inbound route matched", "to_number", message.ToNumber, "keyword", message.Keyword)
+	}
+
+	if err := s.messageRepo.Create(ctx, message); err != nil {
+		// A concurrent callback for the same message may have won the race.
+		if existing, getErr := s.messageRepo.GetByProviderMessageID(ctx, input.Provider, input.ProviderMessageID); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	return message, nil
+}
+
+func (s *inboundService) GetUserHistory(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.InboundMessage, error) {
+	return s.messageRepo.GetUserHistory(ctx, userID, pageSize, (page-1)*pageSize)
+}
+
+func (s *inboundService) ListRoutes(ctx context.Context, userID uint64) ([]entity.InboundRoute, error) {
+	return s.routeRepo.List(ctx, userID)
+}
+
+func (s *inboundService) CreateRoute(ctx context.Context, route *entity.InboundRoute) error {
+	if _, err := s.userRepo.GetByID(ctx, route.UserID); err != nil {
+		return errors.ParseDatabaseError(err)
+	}
+
+	route.Keyword = strings.ToUpper(strings.TrimSpace(route.Keyword))
+	if err := s.routeRepo.Create(ctx, route); err != nil {
+		return errors.ParseDatabaseError(err)
+	}
+	return nil
+}
+
+func (s *inboundService) DeleteRoute(ctx context.Context, routeID uint64) error {
+	deleted, err := s.routeRepo.Delete(ctx, routeID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.ErrInboundRouteNotFound
+	}
+	return nil
+}
+
+// resolveRoute picks the route for the keyword on the number, falling back to
+// the number's catch-all route. It returns nil when neither exists.
+func (s *inboundService) resolveRoute(ctx context.Context, number string, keyword string) (*entity.InboundRoute, error) {
+	routes, err := s.routeRepo.ListByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var catchAll *entity.InboundRoute
+	for i := range routes {
+		switch routes[i].Keyword {
+		case keyword:
+			if keyword != "" {
+				return &routes[i], nil
+			}
+			catchAll = &routes[i]
+		case "":
+			catchAll = &routes[i]
+		}
+	}
+	return catchAll, nil
+}
+
+// inboundKeyword returns the first word of the message, upper-cased, which is
+// how senders address a keyword on a shared number.
+func inboundKeyword(message string) string {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return ""
+	}
+	keyword := strings.ToUpper(fields[0])
+	if len(keyword) > inboundKeywordMaxLength {
+		return ""
+	}
+	return keyword
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type ledgerService struct {
+	ledgerRepo port.LedgerRepository
+	userRepo   port.UserRepository
+	unitOfWork port.UnitOfWork
+	logger     *logger.Logger
+}
+
+func NewLedgerService(
+	ledgerRepo port.LedgerRepository,
+	userRepo port.UserRepository,
+	unitOfWork port.UnitOfWork,
+	logger *logger.Logger,
+) port.LedgerService {
+	return &ledgerService{
+		ledgerRepo: ledgerRepo,
+		userRepo:   userRepo,
+		unitOfWork: unitOfWork,
+		logger:     logger,
+	}
+}
+
+func (s *ledgerService) ListEntries(ctx context.Context, userID uint64, page int, pageSize int) ([]entity.LedgerEntry, int64, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, 0, errors.ParseDatabaseError(err)
+	}
+	return s.ledgerRepo.ListByUser(ctx, userID, pageSize, (page-1)*pageSize)
+}
+
+// Verify reads the credit and the ledger in one transaction, so postings
+// committed in between can't make a consistent balance look off.
+func (s *ledgerService) Verify(ctx context.Context, userID uint64) (*port.LedgerVerification, error) {
+	var verification *port.LedgerVerification
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return errors.ParseDatabaseError(err)
+		}
+
+		summary, err := s.ledgerRepo.Summarize(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		consistent := summary.Sum == user.Credit
+		if summary.LastBalanceAfter != nil {
+			consistent = consistent && *summary.LastBalanceAfter == user.Credit
+		} else {
+			consistent = consistent && user.Credit == 0
+		}
+
+		verification = &port.LedgerVerification{
+			UserID:     userID,
+			Credit:     user.Credit,
+			Ledger:     summary,
+			Consistent: consistent,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !verification.Consistent {
+		s.logger.Warn(ctx, "User credit doesn't match the ledger", "user_id", userID, "credit", verification.Credit, "ledger_sum", verification.Ledger.Sum)
+	}
+	return verification, nil
+}
+
+// AdjustCredit corrects the user's credit by amount, which may be negative,
+// but never below zero.
+func (s *ledgerService) AdjustCredit(ctx context.Context, userID uint64, amount int64, reason string) (*entity.LedgerEntry, error) {
+	if amount == 0 {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Adjustment amount can't be zero")
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	entry, err := s.ledgerRepo.Post(ctx, port.LedgerPosting{
+		UserID:        userID,
+		Type:          entity.LedgerEntryAdjustment,
+		Amount:        amount,
+		ReferenceType: entity.LedgerReferenceAdmin,
+		Description:   reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.ErrInsufficientCredit
+	}
+	return entry, nil
+}
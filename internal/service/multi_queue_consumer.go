@@ -3,8 +3,10 @@ package service
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/config"
 	"github.com/mohammadghasemi1379/sms-gateway/connection"
@@ -14,6 +16,13 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// lanePriorities are the priorities that get their own workers.
+var lanePriorities = []entity.SMSPriorityEnum{
+	entity.SMSPriorityHigh,
+	entity.SMSPriorityNormal,
+	entity.SMSPriorityLow,
+}
+
 type MultiQueueConsumer struct {
 	smsService         port.SMSService
 	transactionService port.TransactionService
@@ -23,6 +32,12 @@ type MultiQueueConsumer struct {
 	logger             *logger.Logger
 	queueStrategy      *QueueDistributionStrategy
 	config             config.RabbitMQConfig
+
+	// lanes holds the workers shared by the queues of each priority.
+	lanes map[entity.SMSPriorityEnum]chan struct{}
+
+	mu         sync.RWMutex
+	queueConns map[string]*connection.RabbitMQConnection
 }
 
 func NewMultiQueueConsumer(
@@ -35,6 +50,15 @@ func NewMultiQueueConsumer(
 	prefetchCount int,
 	config config.RabbitMQConfig,
 ) *MultiQueueConsumer {
+	lanes := make(map[entity.SMSPriorityEnum]chan struct{})
+	for _, priority := range lanePriorities {
+		workers, ok := config.LaneWorkers[string(priority)]
+		if !ok {
+			workers = runtime.NumCPU()
+		}
+		lanes[priority] = make(chan struct{}, workers)
+	}
+
 	return &MultiQueueConsumer{
 		smsService:         smsService,
 		transactionService: transactionService,
@@ -44,6 +68,8 @@ func NewMultiQueueConsumer(
 		logger:             logger,
 		queueStrategy:      NewQueueDistributionStrategy(logger, rabbitMQConnection, prefetchCount, config),
 		config:             config,
+		lanes:              lanes,
+		queueConns:         make(map[string]*connection.RabbitMQConnection),
 	}
 }
 
@@ -71,51 +97,83 @@ func (c *MultiQueueConsumer) ConsumeAllQueues(ctx context.Context) error {
 	return nil
 }
 
+// CheckConsumers reports an error unless every queue has a running consumer
+// with an open connection.
+func (c *MultiQueueConsumer) CheckConsumers(ctx context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, queueName := range c.queueStrategy.GetQueueNames() {
+		queueConn, running := c.queueConns[queueName]
+		if !running {
+			return fmt.Errorf("consumer for queue %s is not running", queueName)
+		}
+		if !queueConn.IsConnected() {
+			return fmt.Errorf("consumer for queue %s is disconnected", queueName)
+		}
+	}
+	return nil
+}
+
 func (c *MultiQueueConsumer) initializeQueues() error {
 	queueNames := c.queueStrategy.GetQueueNames()
-	return c.rabbitMQConnection.DeclareMultipleQueues(queueNames)
+	if err := c.rabbitMQConnection.DeclareMultipleQueues(queueNames); err != nil {
+		return err
+	}
+	if err := c.rabbitMQConnection.DeclareDeadLetterQueues(queueNames); err != nil {
+		return err
+	}
+	return c.queueStrategy.DeclareRetryQueues()
 }
 
 func (c *MultiQueueConsumer) consumeQueue(ctx context.Context, queueName string) error {
+	priority := c.queueStrategy.QueuePriority(queueName)
 	queueConn := connection.NewRabbitMQConnection(
 		c.config,
 		c.logger,
 		fmt.Sprintf("consumer-%s", queueName),
 		"sms-gateway",
 		queueName,
-	)
+	).WithWorkerPool(c.lanes[priority])
 
 	if err := queueConn.Connect(); err != nil {
 		return fmt.Errorf("failed to connect to queue %s: %w", queueName, err)
 	}
 	defer queueConn.Close()
 
+	c.mu.Lock()
+	c.queueConns[queueName] = queueConn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.queueConns, queueName)
+		c.mu.Unlock()
+	}()
+
 	queueConn.ConnectionOpener()
 
 	queueConn.HandleConsumedDeliveries(ctx, c.config.PrefetchCount, func(ctx context.Context, logger *logger.Logger, conn connection.RabbitMQConnection, delivery amqp.Delivery) {
-		c.processMessage(ctx, delivery, queueName)
+		c.processMessage(ctx, queueConn, delivery, queueName)
 	})
 
 	return nil
 }
 
-func (c *MultiQueueConsumer) processMessage(ctx context.Context, delivery amqp.Delivery, queueName string) {
+func (c *MultiQueueConsumer) processMessage(ctx context.Context, queueConn *connection.RabbitMQConnection, delivery amqp.Delivery, queueName string) {
 	c.logger.Info(ctx, "Processing message", "queue", queueName, "delivery_tag", delivery.DeliveryTag)
 
 	smsID, err := strconv.ParseUint(string(delivery.Body), 10, 64)
 	if err != nil {
 		c.logger.Error(ctx, "failed to parse sms id", "error", err.Error(), "queue", queueName)
-		if nackErr := delivery.Nack(false, false); nackErr != nil {
-			c.logger.Error(ctx, "Failed to Nack message after unmarshalling error", nackErr.Error())
+		if dlqErr := queueConn.DeadLetter(delivery, fmt.Sprintf("invalid sms id: %s", err.Error())); dlqErr != nil {
+			c.logger.Error(ctx, "Failed to dead-letter message after unmarshalling error", dlqErr.Error())
 		}
 		return
 	}
 
-	if err := c.processMessageLogic(ctx, smsID); err != nil {
+	if err := c.processMessageLogic(ctx, smsID, delivery.Redelivered); err != nil {
 		c.logger.Error(ctx, "Failed to process sms message", "error", err.Error(), "queue", queueName)
-		if nackErr := delivery.Nack(false, true); nackErr != nil {
-			c.logger.Error(ctx, "failed to Nack", nackErr.Error())
-		}
+		c.retryOrFail(ctx, delivery, queueName, smsID, err)
 		return
 	}
 
@@ -126,25 +184,115 @@ func (c *MultiQueueConsumer) processMessage(ctx context.Context, delivery amqp.D
 	c.logger.Info(ctx, "Message processed successfully", "queue", queueName, "sms_id", smsID)
 }
 
-func (c *MultiQueueConsumer) processMessageLogic(ctx context.Context, smsID uint64) error {
+// retryOrFail schedules the next attempt of a message that failed with cause
+// through the delay queues, or fails the SMS once it has used up its attempts.
+// The delivery is only requeued right away when neither works out.
+func (c *MultiQueueConsumer) retryOrFail(ctx context.Context, delivery amqp.Delivery, queueName string, smsID uint64, cause error) {
+	attempts := connection.DeliveryAttempts(delivery) + 1
+	if err := c.smsService.RecordAttempt(ctx, smsID, attempts, cause.Error()); err != nil {
+		c.logger.Error(ctx, "failed to record sms attempt", "error", err.Error(), "sms_id", smsID)
+	}
+
+	// Without a backoff schedule there's no delay queue to retry through, and
+	// requeueing right away would just spin, so the attempt is the last one.
+	if attempts >= c.config.RetryMaxAttempts || c.queueStrategy.RetryDelay(attempts) == 0 {
+		reason := fmt.Sprintf("gave up after %d attempts: %s", attempts, cause.Error())
+		if _, err := c.smsService.FailSMS(ctx, smsID, reason); err != nil {
+			c.requeue(ctx, delivery)
+			return
+		}
+		c.logger.Warn(ctx, "Failed sms after its last attempt", "sms_id", smsID, "attempts", attempts)
+	} else {
+		body := connection.RabbitMQMessageBody{
+			Data:     delivery.Body,
+			Type:     "sms",
+			Attempts: attempts,
+		}
+		if err := c.queueStrategy.PublishRetry(ctx, queueName, body); err != nil {
+			c.logger.Error(ctx, "failed to schedule sms retry", "error", err.Error(), "sms_id", smsID)
+			c.requeue(ctx, delivery)
+			return
+		}
+		c.logger.Info(ctx, "Scheduled sms retry", "sms_id", smsID, "attempts", attempts, "delay", c.queueStrategy.RetryDelay(attempts).String())
+	}
+
+	if ackErr := delivery.Ack(false); ackErr != nil {
+		c.logger.Error(ctx, "Error on ack message", ackErr.Error())
+	}
+}
+
+func (c *MultiQueueConsumer) requeue(ctx context.Context, delivery amqp.Delivery) {
+	if nackErr := delivery.Nack(false, true); nackErr != nil {
+		c.logger.Error(ctx, "failed to Nack", nackErr.Error())
+	}
+}
+
+// processMessageLogic hands a pending SMS to the provider. The SMS is claimed
+// first, so a cancel or expiry can't refund it while it's being sent. A
+// redelivered message takes over a claim left by a worker that went away
+// before acking it, which may send that message twice.
+func (c *MultiQueueConsumer) processMessageLogic(ctx context.Context, smsID uint64, redelivered bool) error {
 	sms, err := c.smsService.GetSMSByID(ctx, smsID)
 	if err != nil {
 		c.logger.Error(ctx, "failed to get sms", "error", err.Error())
 		return err
 	}
 
+	if sms.Status == entity.SMSStatusPending && sms.IsExpired(time.Now()) {
+		expired, err := c.smsService.ExpireSMS(ctx, smsID)
+		if err != nil {
+			return err
+		}
+		if expired {
+			c.logger.Info(ctx, "Dropped expired sms", "sms_id", smsID, "expires_at", sms.ExpiresAt)
+		}
+		return nil
+	}
+
+	claimed, err := c.smsService.ClaimSMS(ctx, smsID, redelivered)
+	if err != nil {
+		c.logger.Error(ctx, "failed to claim sms", "error", err.Error(), "sms_id", smsID)
+		return err
+	}
+	if !claimed {
+		c.logger.Info(ctx, "Skipping sms that is no longer pending", "sms_id", smsID, "status", sms.Status)
+		return nil
+	}
+
 	response, err := c.provider.Send(ctx, sms)
 	if err != nil {
 		c.logger.Error(ctx, "provider failed to respond", "error", err.Error(), "sms_id", smsID)
+		c.release(ctx, smsID)
 		return err
 	}
 
 	if response.Status == "ok" && response.Message == "sended" {
-		c.smsService.UpdateSMSStatus(ctx, smsID, entity.SMSStatusSent)
-		c.transactionService.UpdateTransactionStatus(ctx, smsID, entity.TransactionSuccess)
+		sent, err := c.smsService.CompleteSMS(ctx, smsID)
+		if err != nil {
+			// The provider accepted the message, so sending it again would
+			// duplicate it. Reconciliation reports the SMS left in SENDING.
+			c.logger.Error(ctx, "failed to record sent sms", "error", err.Error(), "sms_id", smsID)
+			return nil
+		}
+		if !sent {
+			c.logger.Warn(ctx, "Sent sms lost its claim", "sms_id", smsID)
+		}
 		return nil
 	}
 
-	c.logger.Error(ctx, "provider returned not ok response", "status", response.Status, "message", response.Message, "sms_id", smsID)
-	return fmt.Errorf("provider returned not ok response: status=%s, message=%s", response.Status, response.Message)
+	// The provider rejected the message for good, so retrying won't help.
+	c.logger.Error(ctx, "provider rejected sms", "status", response.Status, "message", response.Message, "sms_id", smsID)
+	c.release(ctx, smsID)
+	if _, err := c.smsService.FailSMS(ctx, smsID, fmt.Sprintf("provider rejected: %s", response.Message)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// release gives up the claim on an SMS that wasn't sent, so it can be retried,
+// cancelled or expired.
+func (c *MultiQueueConsumer) release(ctx context.Context, smsID uint64) {
+	if err := c.smsService.ReleaseSMS(ctx, smsID); err != nil {
+		c.logger.Error(ctx, "failed to release sms claim", "error", err.Error(), "sms_id", smsID)
+	}
 }
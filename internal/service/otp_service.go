@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/ratelimit"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	"gorm.io/gorm"
+)
+
+type otpService struct {
+	otpRepo    port.OTPRepository
+	smsService port.SMSService
+	limiter    ratelimit.Limiter
+	logger     *logger.Logger
+	config     config.OTPConfig
+}
+
+func NewOTPService(
+	otpRepo port.OTPRepository,
+	smsService port.SMSService,
+	limiter ratelimit.Limiter,
+	logger *logger.Logger,
+	config config.OTPConfig,
+) port.OTPService {
+	return &otpService{
+		otpRepo:    otpRepo,
+		smsService: smsService,
+		limiter:    limiter,
+		logger:     logger,
+		config:     config,
+	}
+}
+
+// SendOTP generates a new code for the recipient and sends it on the high
+// priority queue, counted against the caps of the API key it's sent with. Only
+// a keyed hash of the code is stored.
+func (s *otpService) SendOTP(ctx context.Context, userID uint64, apiKeyID *uint64, receiveNumber string) (*entity.OTP, error) {
+	latest, err := s.otpRepo.GetLatest(ctx, userID, receiveNumber)
+	if err != nil && !stderrors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.config.ResendCooldown {
+		return nil, errors.ErrOTPResendCooldown
+	}
+
+	code, err := s.generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	otp := &entity.OTP{
+		UserID:        userID,
+		ReceiveNumber: receiveNumber,
+		CodeHash:      s.hashCode(userID, receiveNumber, code),
+		MaxAttempts:   s.config.MaxAttempts,
+		ExpiresAt:     time.Now().Add(s.config.TTL),
+	}
+	if err := s.otpRepo.Create(ctx, otp); err != nil {
+		return nil, err
+	}
+
+	sms := &entity.SMS{
+		UserID:        userID,
+		APIKeyID:      apiKeyID,
+		ReceiveNumber: receiveNumber,
+		Message:       strings.ReplaceAll(s.config.Template, "{code}", code),
+		Status:        entity.SMSStatusPending,
+		Priority:      entity.SMSPriorityHigh,
+		// A code delivered after it expired is useless.
+		ExpiresAt: &otp.ExpiresAt,
+	}
+	if err := s.smsService.SendSMS(ctx, sms); err != nil {
+		// The code never reached the recipient, so it must not be usable.
+		if expireErr := s.otpRepo.Expire(ctx, otp.ID); expireErr != nil {
+			s.logger.Error(ctx, "failed to expire unsent otp", "error", expireErr.Error(), "otp_id", otp.ID)
+		}
+		return nil, err
+	}
+
+	if err := s.otpRepo.SetSMSID(ctx, otp.ID, sms.ID); err != nil {
+		s.logger.Error(ctx, "failed to link otp to sms", "error", err.Error(), "otp_id", otp.ID, "sms_id", sms.ID)
+	}
+	otp.SMSID = &sms.ID
+
+	return otp, nil
+}
+
+// VerifyOTP checks code against the latest code sent to the recipient. Every
+// attempt counts, whether it matches or not, and a code can be used once.
+func (s *otpService) VerifyOTP(ctx context.Context, userID uint64, receiveNumber string, code string) error {
+	result, err := s.limiter.Allow(ctx, "otp-verify:"+receiveNumber, s.config.VerifyLimit, s.config.VerifyWindow)
+	if err != nil {
+		s.logger.Error(ctx, "failed to check otp verify rate limit", "error", err.Error())
+	} else if !result.Allowed {
+		return errors.ErrRateLimited
+	}
+
+	otp, err := s.otpRepo.GetLatest(ctx, userID, receiveNumber)
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.ErrOTPExpired
+		}
+		return err
+	}
+	if otp.VerifiedAt != nil || otp.IsExpired(time.Now()) {
+		return errors.ErrOTPExpired
+	}
+
+	counted, err := s.otpRepo.IncrementAttempts(ctx, otp.ID)
+	if err != nil {
+		return err
+	}
+	if !counted {
+		return errors.ErrOTPAttemptsExceeded
+	}
+
+	expected := s.hashCode(userID, receiveNumber, code)
+	if !hmac.Equal([]byte(expected), []byte(otp.CodeHash)) {
+		return errors.ErrOTPInvalid
+	}
+
+	verified, err := s.otpRepo.MarkVerified(ctx, otp.ID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errors.ErrOTPExpired
+	}
+
+	return nil
+}
+
+func (s *otpService) generateCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(s.config.Length)), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", s.config.Length, n), nil
+}
+
+// hashCode binds the code to the user and recipient, so a stored hash is
+// useless for any other recipient even with the same code.
+func (s *otpService) hashCode(userID uint64, receiveNumber string, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Secret))
+	fmt.Fprintf(mac, "%d:%s:%s", userID, receiveNumber, code)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+const (
+	outboxPruneInterval = 10 * time.Minute
+	outboxPruneBatch    = 1000
+	// outboxMaxTenants caps the users one pass schedules between. With more
+	// waiting, passes page through them in order of id.
+	outboxMaxTenants = 1000
+)
+
+// errOutboxPublishFailed ends a pass early once a message couldn't be
+// published.
+var errOutboxPublishFailed = stderrors.New("outbox message could not be published")
+
+// OutboxRelay publishes the outbox to the broker. Each pass locks due
+// messages with SKIP LOCKED, so several instances can relay side by side
+// without publishing the same message twice. A message that can't be
+// published stays in the outbox and is retried with backoff, so a broker
+// outage only delays messages.
+//
+// Delivery is at least once: should the pass fail to commit after publishing,
+// its messages are published again by a later pass.
+type OutboxRelay struct {
+	outboxRepo    port.OutboxRepository
+	unitOfWork    port.UnitOfWork
+	queueStrategy *QueueDistributionStrategy
+	scheduler     *fairScheduler
+	logger        *logger.Logger
+	config        config.OutboxConfig
+
+	// sampledAt and publishedSince track what was published since the queue
+	// depths were last sampled.
+	sampledAt      time.Time
+	publishedSince int
+}
+
+func NewOutboxRelay(
+	outboxRepo port.OutboxRepository,
+	unitOfWork port.UnitOfWork,
+	queueStrategy *QueueDistributionStrategy,
+	logger *logger.Logger,
+	config config.OutboxConfig,
+) *OutboxRelay {
+	return &OutboxRelay{
+		outboxRepo:    outboxRepo,
+		unitOfWork:    unitOfWork,
+		queueStrategy: queueStrategy,
+		scheduler:     newFairScheduler(config.FairQuantum, config.PlanWeights),
+		logger:        logger,
+		config:        config,
+	}
+}
+
+// Run relays due messages every PollInterval, and prunes dispatched ones now
+// and then, until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(outboxPruneInterval)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Keep going while passes come back full, so a backlog drains
+			// without waiting for the next tick.
+			for ctx.Err() == nil {
+				dispatched, err := r.relay(ctx)
+				if err != nil || dispatched < r.config.BatchSize {
+					break
+				}
+			}
+		case <-pruneTicker.C:
+			r.prune(ctx)
+		}
+	}
+}
+
+// relay runs one pass and returns how many messages it dispatched. High
+// priority messages go first, oldest first, as they are few and urgent. The
+// rest is shared between users by the fair scheduler, within what the queues
+// have room for.
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	var count int
+	err := r.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		urgent, err := r.outboxRepo.LockDueByPriority(ctx, entity.SMSPriorityHigh, r.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		dispatched, err := r.publishAll(ctx, urgent)
+
+		if err == nil {
+			var tenants []port.OutboxTenant
+			tenants, err = r.dueTenants(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = r.scheduler.schedule(tenants, r.budget(), func(userID uint64, allowance int) (int, bool, error) {
+				messages, err := r.outboxRepo.LockDueForUser(ctx, userID, allowance)
+				if err != nil {
+					return 0, false, err
+				}
+				published, err := r.publishAll(ctx, messages)
+				dispatched = append(dispatched, published...)
+				return len(published), len(messages) < allowance, err
+			})
+		}
+		// What was published before a failed publish is still dispatched.
+		if err != nil && !stderrors.Is(err, errOutboxPublishFailed) {
+			return err
+		}
+
+		count = len(dispatched)
+		return r.outboxRepo.MarkDispatched(ctx, dispatched)
+	})
+	if err != nil {
+		r.logger.Error(ctx, "Failed to relay outbox", "error", err.Error())
+		return 0, err
+	}
+	r.publishedSince += count
+	return count, nil
+}
+
+// dueTenants returns the users the scheduler picks up from: those from the
+// user who had the last turn on, wrapping around to the lowest ids when that
+// leaves room, so every waiting user comes up however many there are.
+func (r *OutboxRelay) dueTenants(ctx context.Context) ([]port.OutboxTenant, error) {
+	from := r.scheduler.last
+	tenants, err := r.outboxRepo.ListDueTenants(ctx, from, outboxMaxTenants)
+	if err != nil || from == 0 || len(tenants) == outboxMaxTenants {
+		return tenants, err
+	}
+
+	wrapped, err := r.outboxRepo.ListDueTenants(ctx, 0, outboxMaxTenants-len(tenants))
+	if err != nil {
+		return nil, err
+	}
+	for _, tenant := range wrapped {
+		if tenant.UserID >= from {
+			break
+		}
+		tenants = append(tenants, tenant)
+	}
+	return sortedByUser(tenants), nil
+}
+
+// publishAll publishes messages in order and returns the ids of those it
+// published. It stops at the first message that can't be published, as the
+// broker is then most likely unavailable for the rest as well, and schedules
+// that message's retry.
+func (r *OutboxRelay) publishAll(ctx context.Context, messages []entity.OutboxMessage) ([]uint64, error) {
+	published := make([]uint64, 0, len(messages))
+	for _, message := range messages {
+		if err := r.publish(ctx, message); err != nil {
+			attempts := message.Attempts + 1
+			delay := r.retryDelay(attempts)
+			r.logger.Warn(ctx, "Failed to relay outbox message", "error", err.Error(), "sms_id", message.SMSID, "attempts", attempts, "retry_in", delay.String())
+
+			lastError := truncate(err.Error(), maxErrorLength)
+			if err := r.outboxRepo.Reschedule(ctx, message.ID, time.Now().Add(delay), lastError); err != nil {
+				return published, err
+			}
+			return published, errOutboxPublishFailed
+		}
+		published = append(published, message.ID)
+	}
+	return published, nil
+}
+
+// budget returns how many normal and low priority messages this pass may
+// publish: a batch, or less when MaxQueueDepth leaves less room. Without
+// fresh queue depths there's nothing to hold back for.
+func (r *OutboxRelay) budget() int {
+	if r.config.MaxQueueDepth <= 0 {
+		return r.config.BatchSize
+	}
+	backlog, sampledAt, ok := r.queueStrategy.Backlog()
+	if !ok {
+		return r.config.BatchSize
+	}
+
+	// Passes between two samples share the room the last sample showed.
+	if !sampledAt.Equal(r.sampledAt) {
+		r.sampledAt, r.publishedSince = sampledAt, 0
+	}
+	room := r.config.MaxQueueDepth - int(backlog) - r.publishedSince
+	return min(r.config.BatchSize, max(room, 0))
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, message entity.OutboxMessage) error {
+	body := connection.RabbitMQMessageBody{
+		Data: fmt.Appendf(nil, "%d", message.SMSID),
+		Type: "sms",
+	}
+	if message.ExpiresAt != nil {
+		body.Expiration = time.Until(*message.ExpiresAt)
+		if body.Expiration <= 0 {
+			// Nobody would get it in time; the expiry sweeper refunds it.
+			r.logger.Info(ctx, "Dropped expired outbox message", "sms_id", message.SMSID, "expires_at", message.ExpiresAt)
+			return nil
+		}
+	}
+	return r.queueStrategy.PublishToQueue(ctx, body, message.Priority, message.UserID)
+}
+
+// retryDelay doubles RetryDelay with every failed attempt, up to
+// MaxRetryDelay.
+func (r *OutboxRelay) retryDelay(attempts int) time.Duration {
+	delay := r.config.RetryDelay << min(attempts-1, 30)
+	if delay <= 0 || delay > r.config.MaxRetryDelay {
+		delay = r.config.MaxRetryDelay
+	}
+	return delay
+}
+
+func (r *OutboxRelay) prune(ctx context.Context) {
+	deleted, err := r.outboxRepo.DeleteDispatched(ctx, time.Now().Add(-r.config.Retention), outboxPruneBatch)
+	if err != nil {
+		return
+	}
+	if deleted > 0 {
+		r.logger.Info(ctx, "Pruned dispatched outbox messages", "deleted", deleted)
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+const (
+	OverflowStrategyWeightedRandom = "weighted_random"
+	OverflowStrategyRoundRobin     = "round_robin"
+	OverflowStrategyLeastLoaded    = "least_loaded"
+	OverflowStrategyConsistentHash = "consistent_hash"
+)
+
+// OverflowQueue is a queue normal traffic can overflow to, along with its
+// configured weight and, when known, its sampled depth.
+type OverflowQueue struct {
+	Name       string
+	Weight     int
+	Depth      int64
+	DepthKnown bool
+}
+
+// OverflowStrategy picks the queue a message of userID overflows to once the
+// main queue is backed up. It gets everything it decides on as arguments, so
+// implementations don't need a broker.
+type OverflowStrategy interface {
+	Select(userID uint64, queues []OverflowQueue) string
+}
+
+// NewOverflowStrategy returns the strategy of the given name, and false when
+// there is no such strategy.
+func NewOverflowStrategy(name string) (OverflowStrategy, bool) {
+	switch name {
+	case OverflowStrategyWeightedRandom:
+		return &weightedRandomStrategy{intN: rand.IntN}, true
+	case OverflowStrategyRoundRobin:
+		return &roundRobinStrategy{}, true
+	case OverflowStrategyLeastLoaded:
+		return leastLoadedStrategy{}, true
+	case OverflowStrategyConsistentHash:
+		return consistentHashStrategy{}, true
+	default:
+		return nil, false
+	}
+}
+
+// weightedRandomStrategy picks a queue at random in proportion to the
+// weights.
+type weightedRandomStrategy struct {
+	intN func(n int) int
+}
+
+func (s *weightedRandomStrategy) Select(_ uint64, queues []OverflowQueue) string {
+	total := 0
+	for _, queue := range queues {
+		total += max(queue.Weight, 0)
+	}
+	if total == 0 {
+		return queues[0].Name
+	}
+
+	pick := s.intN(total)
+	for _, queue := range queues {
+		pick -= max(queue.Weight, 0)
+		if pick < 0 {
+			return queue.Name
+		}
+	}
+	return queues[len(queues)-1].Name
+}
+
+// roundRobinStrategy takes turns over the queues, ignoring the weights.
+type roundRobinStrategy struct {
+	next atomic.Uint64
+}
+
+func (s *roundRobinStrategy) Select(_ uint64, queues []OverflowQueue) string {
+	turn := s.next.Add(1) - 1
+	return queues[turn%uint64(len(queues))].Name
+}
+
+// leastLoadedStrategy picks the queue with the fewest messages. Queues whose
+// depth is unknown are only picked when no depth is known at all.
+type leastLoadedStrategy struct{}
+
+func (leastLoadedStrategy) Select(_ uint64, queues []OverflowQueue) string {
+	best := -1
+	for i, queue := range queues {
+		if !queue.DepthKnown {
+			continue
+		}
+		if best < 0 || queue.Depth < queues[best].Depth {
+			best = i
+		}
+	}
+	if best < 0 {
+		return queues[0].Name
+	}
+	return queues[best].Name
+}
+
+// consistentHashStrategy sends all of a user's overflow to the same queue,
+// which keeps their messages in order. It uses weighted rendezvous hashing:
+// every queue scores the user and the highest score wins, so adding or
+// removing a queue only moves the users of that queue.
+type consistentHashStrategy struct{}
+
+func (consistentHashStrategy) Select(userID uint64, queues []OverflowQueue) string {
+	best, bestScore := queues[0].Name, math.Inf(-1)
+	for _, queue := range queues {
+		if queue.Weight <= 0 {
+			continue
+		}
+
+		hash := fnv.New64a()
+		var key [8]byte
+		for i := range key {
+			key[i] = byte(userID >> (8 * i))
+		}
+		hash.Write(key[:])
+		hash.Write([]byte(queue.Name))
+
+		// Map the hash to (0, 1) and weigh it, so a queue's share of users
+		// follows its weight.
+		unit := (float64(hash.Sum64()>>11) + 0.5) / (1 << 53)
+		score := -float64(queue.Weight) / math.Log(unit)
+		if score > bestScore {
+			best, bestScore = queue.Name, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func overflowQueues(weights ...int) []OverflowQueue {
+	names := []string{QueueNameSMSPrimary, QueueNameSMSSecondary, "sms-gateway-tertiary"}
+	queues := make([]OverflowQueue, len(weights))
+	for i, weight := range weights {
+		queues[i] = OverflowQueue{Name: names[i], Weight: weight}
+	}
+	return queues
+}
+
+func TestNewOverflowStrategy(t *testing.T) {
+	for _, name := range []string{
+		OverflowStrategyWeightedRandom,
+		OverflowStrategyRoundRobin,
+		OverflowStrategyLeastLoaded,
+		OverflowStrategyConsistentHash,
+	} {
+		if _, ok := NewOverflowStrategy(name); !ok {
+			t.Errorf("NewOverflowStrategy(%q) found no strategy", name)
+		}
+	}
+	if _, ok := NewOverflowStrategy("fastest"); ok {
+		t.Error("NewOverflowStrategy(\"fastest\") found a strategy")
+	}
+}
+
+func TestWeightedRandomStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []int
+		want    []int // picks per queue over every value intN can return
+	}{
+		{name: "default weights", weights: []int{90, 10}, want: []int{90, 10}},
+		{name: "three queues", weights: []int{1, 2, 3}, want: []int{1, 2, 3}},
+		{name: "zero weight never picked", weights: []int{0, 5}, want: []int{0, 5}},
+		{name: "negative weight never picked", weights: []int{-3, 4}, want: []int{0, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queues := overflowQueues(tt.weights...)
+
+			// Step intN through all its values, so each queue is picked
+			// exactly as often as its weight says.
+			next := 0
+			strategy := &weightedRandomStrategy{intN: func(n int) int {
+				pick := next % n
+				next++
+				return pick
+			}}
+
+			total := 0
+			for _, weight := range tt.weights {
+				total += max(weight, 0)
+			}
+			got := make([]int, len(queues))
+			for range total {
+				picked := strategy.Select(0, queues)
+				got[slices.IndexFunc(queues, func(q OverflowQueue) bool { return q.Name == picked })]++
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("picks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedRandomStrategyFollowsWeights(t *testing.T) {
+	const picks = 100000
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	strategy := &weightedRandomStrategy{intN: rng.IntN}
+	queues := overflowQueues(90, 10)
+
+	primary := 0
+	for range picks {
+		if strategy.Select(0, queues) == QueueNameSMSPrimary {
+			primary++
+		}
+	}
+	if share := float64(primary) / picks; math.Abs(share-0.9) > 0.01 {
+		t.Errorf("primary share = %.3f, want 0.9", share)
+	}
+}
+
+func TestWeightedRandomStrategyWithoutWeights(t *testing.T) {
+	strategy := &weightedRandomStrategy{intN: func(int) int {
+		t.Fatal("intN called without weights")
+		return 0
+	}}
+	if got := strategy.Select(0, overflowQueues(0, 0)); got != QueueNameSMSPrimary {
+		t.Errorf("Select = %q, want %q", got, QueueNameSMSPrimary)
+	}
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	strategy := &roundRobinStrategy{}
+	queues := overflowQueues(90, 10, 1)
+
+	var got []string
+	for userID := range uint64(7) {
+		got = append(got, strategy.Select(userID, queues))
+	}
+
+	want := []string{
+		QueueNameSMSPrimary, QueueNameSMSSecondary, "sms-gateway-tertiary",
+		QueueNameSMSPrimary, QueueNameSMSSecondary, "sms-gateway-tertiary",
+		QueueNameSMSPrimary,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("picks = %v, want %v", got, want)
+	}
+}
+
+func TestLeastLoadedStrategy(t *testing.T) {
+	tests := []struct {
+		name   string
+		queues []OverflowQueue
+		want   string
+	}{
+		{
+			name: "fewest messages",
+			queues: []OverflowQueue{
+				{Name: "a", Depth: 500, DepthKnown: true},
+				{Name: "b", Depth: 20, DepthKnown: true},
+				{Name: "c", Depth: 300, DepthKnown: true},
+			},
+			want: "b",
+		},
+		{
+			name: "tie goes to the first",
+			queues: []OverflowQueue{
+				{Name: "a", Depth: 20, DepthKnown: true},
+				{Name: "b", Depth: 20, DepthKnown: true},
+			},
+			want: "a",
+		},
+		{
+			name: "unknown depth skipped",
+			queues: []OverflowQueue{
+				{Name: "a"},
+				{Name: "b", Depth: 900, DepthKnown: true},
+			},
+			want: "b",
+		},
+		{
+			name: "no depth known",
+			queues: []OverflowQueue{
+				{Name: "a"},
+				{Name: "b"},
+			},
+			want: "a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (leastLoadedStrategy{}).Select(0, tt.queues); got != tt.want {
+				t.Errorf("Select = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsistentHashStrategyIsStable(t *testing.T) {
+	strategy := consistentHashStrategy{}
+	queues := overflowQueues(90, 10, 50)
+
+	for userID := range uint64(1000) {
+		first := strategy.Select(userID, queues)
+		for range 3 {
+			if got := strategy.Select(userID, queues); got != first {
+				t.Fatalf("user %d moved from %q to %q", userID, first, got)
+			}
+		}
+	}
+}
+
+func TestConsistentHashStrategyRemovingQueueOnlyMovesItsUsers(t *testing.T) {
+	const users = 10000
+
+	strategy := consistentHashStrategy{}
+	queues := overflowQueues(1, 1, 1)
+	removed := queues[1].Name
+	remaining := []OverflowQueue{queues[0], queues[2]}
+
+	moved := 0
+	for userID := range uint64(users) {
+		before := strategy.Select(userID, queues)
+		after := strategy.Select(userID, remaining)
+		if before != removed && after != before {
+			t.Fatalf("user %d moved from %q to %q though %q is still there", userID, before, after, before)
+		}
+		if before == removed {
+			moved++
+		}
+	}
+
+	// Only the removed queue's users move, about a third of them.
+	if share := float64(moved) / users; math.Abs(share-1.0/3) > 0.03 {
+		t.Errorf("moved share = %.3f, want about 0.333", share)
+	}
+}
+
+func TestConsistentHashStrategyFollowsWeights(t *testing.T) {
+	const users = 20000
+
+	strategy := consistentHashStrategy{}
+	queues := overflowQueues(90, 10)
+
+	primary := 0
+	for userID := range uint64(users) {
+		if strategy.Select(userID, queues) == QueueNameSMSPrimary {
+			primary++
+		}
+	}
+	if share := float64(primary) / users; math.Abs(share-0.9) > 0.02 {
+		t.Errorf("primary share = %.3f, want about 0.9", share)
+	}
+
+	// A queue without weight gets no users.
+	for userID := range uint64(100) {
+		if got := strategy.Select(userID, overflowQueues(0, 1)); got != QueueNameSMSSecondary {
+			t.Fatalf("user %d went to %q, want %q", userID, got, QueueNameSMSSecondary)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	queueDepthMetric       = expvar.NewMap("queue_depth")
+	queueDepthAgeMetric    = expvar.NewMap("queue_depth_age_ms")
+	queueDepthErrorsMetric = expvar.NewInt("queue_depth_sample_errors")
+)
+
+type queueDepthSample struct {
+	messages  int64
+	sampledAt time.Time
+}
+
+// QueueDepthSampler keeps the depth of the SMS queues at hand for routing. It
+// inspects them passively on one long-lived channel every sample interval, so
+// publishing never waits on the broker to read a depth.
+type QueueDepthSampler struct {
+	rabbitMQConnection *connection.RabbitMQConnection
+	queueNames         []string
+	logger             *logger.Logger
+	config             config.RabbitMQConfig
+
+	channel *amqp.Channel
+	depths  map[string]*expvar.Int
+
+	mu      sync.RWMutex
+	samples map[string]queueDepthSample
+}
+
+func NewQueueDepthSampler(
+	rabbitMQConnection *connection.RabbitMQConnection,
+	queueNames []string,
+	logger *logger.Logger,
+	config config.RabbitMQConfig,
+) *QueueDepthSampler {
+	s := &QueueDepthSampler{
+		rabbitMQConnection: rabbitMQConnection,
+		queueNames:         queueNames,
+		logger:             logger,
+		config:             config,
+		depths:             make(map[string]*expvar.Int),
+		samples:            make(map[string]queueDepthSample),
+	}
+	for _, queueName := range queueNames {
+		s.depths[queueName] = new(expvar.Int)
+		queueDepthMetric.Set(queueName, s.depths[queueName])
+		queueDepthAgeMetric.Set(queueName, expvar.Func(func() any {
+			_, age, ok := s.Depth(queueName)
+			if !ok {
+				return nil
+			}
+			return age.Milliseconds()
+		}))
+	}
+	return s
+}
+
+// Run samples every DepthSampleInterval until ctx is cancelled.
+func (s *QueueDepthSampler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.DepthSampleInterval)
+	defer ticker.Stop()
+	defer s.closeChannel()
+
+	s.sample(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample(ctx)
+		}
+	}
+}
+
+// Depth returns the last sampled depth of a queue and how old it is. It
+// reports false when the queue was never sampled.
+func (s *QueueDepthSampler) Depth(queueName string) (int64, time.Duration, bool) {
+	s.mu.RLock()
+	sample, ok := s.samples[queueName]
+	s.mu.RUnlock()
+	if !ok {
+		return 0, 0, false
+	}
+	return sample.messages, time.Since(sample.sampledAt), true
+}
+
+func (s *QueueDepthSampler) sample(ctx context.Context) {
+	for _, queueName := range s.queueNames {
+		if s.channel == nil || s.channel.IsClosed() {
+			channel, err := s.rabbitMQConnection.Channel()
+			if err != nil {
+				queueDepthErrorsMetric.Add(1)
+				s.logger.Error(ctx, "Failed to open queue depth channel", "error", err.Error())
+				return
+			}
+			s.channel = channel
+		}
+
+		// A passive declare only inspects the queue; should it be missing,
+		// the broker closes the channel and it's reopened for the next one.
+		queue, err := s.channel.QueueDeclarePassive(queueName, true, false, false, false, nil)
+		if err != nil {
+			queueDepthErrorsMetric.Add(1)
+			s.logger.Error(ctx, "Failed to sample queue depth", "queue", queueName, "error", err.Error())
+			continue
+		}
+
+		s.mu.Lock()
+		s.samples[queueName] = queueDepthSample{messages: int64(queue.Messages), sampledAt: time.Now()}
+		s.mu.Unlock()
+		s.depths[queueName].Set(int64(queue.Messages))
+	}
+}
+
+func (s *QueueDepthSampler) closeChannel() {
+	if s.channel != nil && !s.channel.IsClosed() {
+		_ = s.channel.Close()
+	}
+}
+
+// Backlog returns how many messages all sampled queues hold together, and
+// when the oldest of the samples was taken. It reports false unless every
+// queue was sampled.
+func (s *QueueDepthSampler) Backlog() (int64, time.Time, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var total int64
+	var oldest time.Time
+	for _, queueName := range s.queueNames {
+		sample, ok := s.samples[queueName]
+		if !ok {
+			return 0, time.Time{}, false
+		}
+		total += sample.messages
+		if oldest.IsZero() || sample.sampledAt.Before(oldest) {
+			oldest = sample.sampledAt
+		}
+	}
+	return total, oldest, true
+}
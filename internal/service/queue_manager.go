@@ -2,27 +2,35 @@ package service
 
 import (
 	"context"
+	"expvar"
 	"fmt"
-	"math/rand"
-	"sync/atomic"
 	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/config"
 	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
 
 const (
 	QueueNameSMSMain      = "sms-gateway"           // Main queue (existing)
-	QueueNameSMSPrimary   = "sms-gateway-primary"   // 90% of overflow traffic
-	QueueNameSMSSecondary = "sms-gateway-secondary" // 10% of overflow traffic
+	QueueNameSMSPrimary   = "sms-gateway-primary"   // Overflow traffic, 90% by weight
+	QueueNameSMSSecondary = "sms-gateway-secondary" // Overflow traffic, 10% by weight
+	QueueNameSMSPriority  = "sms-gateway-priority"  // High priority traffic such as OTPs
+	QueueNameSMSBulk      = "sms-gateway-bulk"      // Low priority bulk traffic such as marketing
+)
+
+var (
+	queueRoutedMetric       = expvar.NewMap("queue_routed")
+	queueRoutingStaleMetric = expvar.NewInt("queue_routing_without_depth")
 )
 
 type QueueDistributionStrategy struct {
 	logger             *logger.Logger
 	rabbitMQConnection *connection.RabbitMQConnection
+	depthSampler       *QueueDepthSampler
+	overflow           OverflowStrategy
 	prefetchCount      int
-	messageCounter     int64
 	config             config.RabbitMQConfig
 }
 
@@ -32,71 +40,118 @@ func NewQueueDistributionStrategy(
 	prefetchCount int,
 	config config.RabbitMQConfig,
 ) *QueueDistributionStrategy {
+	overflow, ok := NewOverflowStrategy(config.OverflowStrategy)
+	if !ok {
+		logger.Warn(context.TODO(), "Unknown overflow strategy, using weighted random", "strategy", config.OverflowStrategy)
+		overflow, _ = NewOverflowStrategy(OverflowStrategyWeightedRandom)
+	}
+
 	return &QueueDistributionStrategy{
 		logger:             logger,
 		rabbitMQConnection: rabbitMQConnection,
+		overflow:           overflow,
 		prefetchCount:      prefetchCount,
-		messageCounter:     0,
 		config:             config,
 	}
 }
 
-func (q *QueueDistributionStrategy) DetermineQueue(ctx context.Context) (string, error) {
+// WithDepthSampler makes routing use the queue depths sampler keeps. Without
+// one, or while its samples are stale, overflow routing is off and normal
+// traffic goes to the main queue.
+func (q *QueueDistributionStrategy) WithDepthSampler(sampler *QueueDepthSampler) *QueueDistributionStrategy {
+	q.depthSampler = sampler
+	return q
+}
+
+// DetermineQueue returns the queue for a message of userID. Normal traffic
+// goes to the main queue until it holds OverflowThreshold messages (the
+// prefetch count by default), and to the queue the overflow strategy picks
+// from then on.
+func (q *QueueDistributionStrategy) DetermineQueue(ctx context.Context, priority entity.SMSPriorityEnum, userID uint64) (string, error) {
+	// High priority messages skip the shared queues, so a backlog there
+	// doesn't delay them
+	if priority == entity.SMSPriorityHigh {
+		return QueueNameSMSPriority, nil
+	}
+	// Bulk traffic gets a lane of its own, so a large campaign doesn't queue
+	// up in front of normal messages either
+	if priority == entity.SMSPriorityLow {
+		return QueueNameSMSBulk, nil
+	}
+
 	mainQueueCount, err := q.getQueueMessageCount(ctx, QueueNameSMSMain)
 	if err != nil {
-		q.logger.Error(ctx, "Failed to get main queue count", "error", err.Error())
+		queueRoutingStaleMetric.Add(1)
+		q.logger.Warn(ctx, "Routing without main queue depth", "error", err.Error())
 		return QueueNameSMSMain, nil
 	}
 
+	threshold := q.config.OverflowThreshold
+	if threshold <= 0 {
+		threshold = q.prefetchCount
+	}
+
 	q.logger.Debug(ctx, "Queue status",
 		"main_queue_count", mainQueueCount,
-		"prefetch_threshold", q.prefetchCount,
+		"overflow_threshold", threshold,
 	)
 
-	if mainQueueCount < int64(q.prefetchCount) {
+	if mainQueueCount < int64(threshold) {
 		return QueueNameSMSMain, nil
 	}
 
-	return q.selectOverflowQueue(ctx), nil
+	return q.selectOverflowQueue(ctx, userID), nil
 }
 
-func (q *QueueDistributionStrategy) selectOverflowQueue(ctx context.Context) string {
-	rand.Seed(time.Now().UnixNano() + atomic.AddInt64(&q.messageCounter, 1))
-	randomValue := rand.Intn(100) + 1
-
-	if randomValue <= q.config.PrimaryWeight {
-		q.logger.Debug(ctx, "Selected primary overflow queue", "random_value", randomValue)
-		return QueueNameSMSPrimary
+func (q *QueueDistributionStrategy) selectOverflowQueue(ctx context.Context, userID uint64) string {
+	queues := []OverflowQueue{
+		{Name: QueueNameSMSPrimary, Weight: q.config.PrimaryWeight},
+		{Name: QueueNameSMSSecondary, Weight: q.config.SecondaryWeight},
+	}
+	for i := range queues {
+		if depth, err := q.getQueueMessageCount(ctx, queues[i].Name); err == nil {
+			queues[i].Depth, queues[i].DepthKnown = depth, true
+		}
 	}
 
-	q.logger.Debug(ctx, "Selected secondary overflow queue", "random_value", randomValue)
-	return QueueNameSMSSecondary
+	queueName := q.overflow.Select(userID, queues)
+	q.logger.Debug(ctx, "Selected overflow queue", "queue", queueName, "strategy", q.config.OverflowStrategy)
+	return queueName
 }
 
+// getQueueMessageCount returns the sampled depth of a queue, failing when it
+// wasn't sampled or the sample is too old to route by.
 func (q *QueueDistributionStrategy) getQueueMessageCount(ctx context.Context, queueName string) (int64, error) {
-	tempConn := connection.NewRabbitMQConnection(
-		q.config,
-		q.logger,
-		"queue-inspector",
-		"sms-gateway", 
-		queueName,
-	)
-
-	if err := tempConn.Connect(); err != nil {
-		return 0, fmt.Errorf("failed to connect to inspect queue: %w", err)
+	if q.depthSampler == nil {
+		return 0, fmt.Errorf("queue depths are not sampled")
 	}
-	defer tempConn.Close()
 
-	count, err := tempConn.GetQueueMessageCount()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get queue message count: %w", err)
+	depth, age, ok := q.depthSampler.Depth(queueName)
+	if !ok {
+		return 0, fmt.Errorf("depth of queue %s was not sampled yet", queueName)
+	}
+	if age > q.config.DepthMaxStaleness {
+		return 0, fmt.Errorf("depth of queue %s is stale, sampled %s ago", queueName, age.Round(time.Millisecond))
 	}
 
-	return int64(count), nil
+	return depth, nil
 }
 
-func (q *QueueDistributionStrategy) PublishToQueue(ctx context.Context, message connection.RabbitMQMessageBody) error {
-	targetQueue, err := q.DetermineQueue(ctx)
+// Backlog returns how many messages the SMS queues hold together and when
+// that was sampled. It reports false when the depths are unknown or stale.
+func (q *QueueDistributionStrategy) Backlog() (int64, time.Time, bool) {
+	if q.depthSampler == nil {
+		return 0, time.Time{}, false
+	}
+	backlog, sampledAt, ok := q.depthSampler.Backlog()
+	if !ok || time.Since(sampledAt) > q.config.DepthMaxStaleness {
+		return 0, time.Time{}, false
+	}
+	return backlog, sampledAt, true
+}
+
+func (q *QueueDistributionStrategy) PublishToQueue(ctx context.Context, message connection.RabbitMQMessageBody, priority entity.SMSPriorityEnum, userID uint64) error {
+	targetQueue, err := q.DetermineQueue(ctx, priority, userID)
 	if err != nil {
 		return fmt.Errorf("failed to determine target queue: %w", err)
 	}
@@ -115,6 +170,7 @@ func (q *QueueDistributionStrategy) PublishToQueue(ctx context.Context, message
 	if err := q.rabbitMQConnection.Publish(ctx, msg); err != nil {
 		return fmt.Errorf("failed to publish to queue %s: %w", targetQueue, err)
 	}
+	queueRoutedMetric.Add(targetQueue, 1)
 
 	return nil
 }
@@ -124,5 +180,68 @@ func (q *QueueDistributionStrategy) GetQueueNames() []string {
 		QueueNameSMSMain,
 		QueueNameSMSPrimary,
 		QueueNameSMSSecondary,
+		QueueNameSMSPriority,
+		QueueNameSMSBulk,
+	}
+}
+
+// RetryQueueName returns the delay queue, and the exchange in front of it,
+// that holds messages for delay. The delay is part of the name, so changing
+// the backoff schedule declares new queues instead of clashing with the TTL
+// of existing ones.
+func RetryQueueName(delay time.Duration) string {
+	return fmt.Sprintf("sms-gateway-retry-%ds", int64(delay.Seconds()))
+}
+
+// RetryDelay returns how long a message waits before the given retry, 1 being
+// the first. Retries past the end of the schedule use its last delay.
+func (q *QueueDistributionStrategy) RetryDelay(retry int) time.Duration {
+	delays := q.config.RetryDelays
+	if len(delays) == 0 {
+		return 0
+	}
+	return delays[min(max(retry, 1), len(delays))-1]
+}
+
+// DeclareRetryQueues declares a delay queue for every delay of the backoff
+// schedule.
+func (q *QueueDistributionStrategy) DeclareRetryQueues() error {
+	for _, delay := range q.config.RetryDelays {
+		if err := q.rabbitMQConnection.DeclareDelayQueue(RetryQueueName(delay), delay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishRetry publishes a message to the delay queue of its next retry, from
+// where the broker moves it back to queueName once the delay has passed.
+func (q *QueueDistributionStrategy) PublishRetry(ctx context.Context, queueName string, message connection.RabbitMQMessageBody) error {
+	delay := q.RetryDelay(message.Attempts)
+	if delay == 0 {
+		return fmt.Errorf("no retry delays configured")
+	}
+
+	msg := connection.RabbitMQMessage{
+		Exchange:    RetryQueueName(delay),
+		Queue:       queueName,
+		ContentType: "text/plain",
+		Body:        message,
+	}
+	if err := q.rabbitMQConnection.Publish(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish retry to %s: %w", msg.Exchange, err)
+	}
+	return nil
+}
+
+// QueuePriority returns the priority of the messages a queue carries.
+func (q *QueueDistributionStrategy) QueuePriority(queueName string) entity.SMSPriorityEnum {
+	switch queueName {
+	case QueueNameSMSPriority:
+		return entity.SMSPriorityHigh
+	case QueueNameSMSBulk:
+		return entity.SMSPriorityLow
+	default:
+		return entity.SMSPriorityNormal
 	}
 }
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type reconciliationService struct {
+	reconciliationRepo port.ReconciliationRepository
+	smsRepo            port.SMSRepository
+	transactionRepo    port.TransactionRepository
+	logger             *logger.Logger
+	config             config.ReconcileConfig
+}
+
+func NewReconciliationService(
+	reconciliationRepo port.ReconciliationRepository,
+	smsRepo port.SMSRepository,
+	transactionRepo port.TransactionRepository,
+	logger *logger.Logger,
+	config config.ReconcileConfig,
+) port.ReconciliationService {
+	return &reconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		smsRepo:            smsRepo,
+		transactionRepo:    transactionRepo,
+		logger:             logger,
+		config:             config,
+	}
+}
+
+// Reconcile only ever repairs statuses that can be derived from the rest of
+// the data. Anything that would move credit, like a missing refund or a
+// balance drift, is reported for an admin to settle.
+func (s *reconciliationService) Reconcile(ctx context.Context, triggeredBy entity.ReconciliationTriggerEnum, repair bool) (*entity.ReconciliationReport, error) {
+	report := &entity.ReconciliationReport{
+		TriggeredBy: triggeredBy,
+		Repair:      repair,
+		StartedAt:   time.Now(),
+	}
+	settled := report.StartedAt.Add(-s.config.Grace)
+
+	orphaned, err := s.reconciliationRepo.FindOrphanedTransactions(ctx, s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range orphaned {
+		report.Findings = append(report.Findings, entity.ReconciliationFinding{
+			Kind:          entity.FindingOrphanedTransaction,
+			UserID:        &transaction.UserID,
+			TransactionID: &transaction.ID,
+			Detail:        fmt.Sprintf("%s debit of %d is not linked to an sms", transaction.Status, transaction.Amount),
+		})
+	}
+
+	unbilled, err := s.reconciliationRepo.FindUnbilledSMS(ctx, settled, s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, sms := range unbilled {
+		report.Findings = append(report.Findings, entity.ReconciliationFinding{
+			Kind:   entity.FindingMissingTransaction,
+			UserID: &sms.UserID,
+			SMSID:  &sms.ID,
+			Detail: fmt.Sprintf("%s sms costing %d has no debit transaction", sms.Status, sms.Cost),
+		})
+	}
+
+	mismatches, err := s.reconciliationRepo.FindStatusMismatches(ctx, settled, s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, mismatch := range mismatches {
+		report.Findings = append(report.Findings, s.checkMismatch(ctx, mismatch, repair))
+	}
+
+	drifts, err := s.reconciliationRepo.FindBalanceDrift(ctx, s.config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, drift := range drifts {
+		report.Findings = append(report.Findings, entity.ReconciliationFinding{
+			Kind:   entity.FindingBalanceDrift,
+			UserID: &drift.UserID,
+			Detail: fmt.Sprintf("credit is %d but the ledger adds up to %d", drift.Credit, drift.LedgerBalance),
+		})
+	}
+
+	for _, finding := range report.Findings {
+		report.Issues++
+		if finding.Repaired {
+			report.Repaired++
+		}
+	}
+	report.FinishedAt = time.Now()
+
+	if err := s.reconciliationRepo.CreateReport(ctx, report); err != nil {
+		return nil, err
+	}
+
+	if report.Issues > 0 {
+		s.logger.Warn(ctx, "Reconciliation found issues", "report_id", report.ID, "issues", report.Issues, "repaired", report.Repaired)
+	}
+	return report, nil
+}
+
+// checkMismatch describes a mismatch between an SMS and its debit, repairing
+// it when the right status follows from the other record:
+//   - a SENT message means the debit succeeded;
+//   - a SUCCESS debit is only recorded after the provider accepted the
+//     message, so the message was sent;
+//   - a refunded message means the debit failed.
+//
+// A message still in SENDING after the grace period is only reported, since
+// only the provider knows whether it went out.
+func (s *reconciliationService) checkMismatch(ctx context.Context, mismatch port.SMSTransactionMismatch, repair bool) entity.ReconciliationFinding {
+	finding := entity.ReconciliationFinding{
+		Kind:          entity.FindingStatusMismatch,
+		UserID:        &mismatch.UserID,
+		SMSID:         &mismatch.SMSID,
+		TransactionID: &mismatch.TransactionID,
+		Detail:        fmt.Sprintf("sms is %s but its debit is %s", mismatch.SMSStatus, mismatch.TransactionStatus),
+	}
+
+	var fix func() (bool, error)
+	switch {
+	case mismatch.SMSStatus == entity.SMSStatusSending:
+		finding.Detail = fmt.Sprintf("sms is stuck in %s and its debit is %s", mismatch.SMSStatus, mismatch.TransactionStatus)
+	case mismatch.SMSStatus == entity.SMSStatusSent && mismatch.TransactionStatus == entity.TransactionPending:
+		fix = func() (bool, error) {
+			return true, s.transactionRepo.UpdateStatusBySMSID(ctx, mismatch.SMSID, entity.TransactionSuccess)
+		}
+	case mismatch.SMSStatus == entity.SMSStatusPending && mismatch.TransactionStatus == entity.TransactionSuccess:
+		fix = func() (bool, error) {
+			return s.smsRepo.ClosePending(ctx, mismatch.SMSID, entity.SMSStatusSent, "")
+		}
+	case mismatch.SMSStatus != entity.SMSStatusSent && mismatch.SMSStatus != entity.SMSStatusPending:
+		if !mismatch.Refunded {
+			finding.Kind = entity.FindingMissingRefund
+			finding.Detail = fmt.Sprintf("sms is %s but its cost was never refunded", mismatch.SMSStatus)
+			break
+		}
+		fix = func() (bool, error) {
+			return true, s.transactionRepo.UpdateStatusBySMSID(ctx, mismatch.SMSID, entity.TransactionFailed)
+		}
+	}
+
+	if repair && fix != nil {
+		repaired, err := fix()
+		if err != nil {
+			s.logger.Error(ctx, "Failed to repair sms status mismatch", "error", err.Error(), "sms_id", mismatch.SMSID)
+		}
+		finding.Repaired = err == nil && repaired
+	}
+	return finding
+}
+
+func (s *reconciliationService) ListReports(ctx context.Context, page int, pageSize int) ([]entity.ReconciliationReport, int64, error) {
+	return s.reconciliationRepo.ListReports(ctx, pageSize, (page-1)*pageSize)
+}
+
+func (s *reconciliationService) GetReport(ctx context.Context, id uint64) (*entity.ReconciliationReport, error) {
+	report, err := s.reconciliationRepo.GetReport(ctx, id)
+	if err != nil {
+		return nil, errors.ParseDatabaseErrorFor(err, errors.ErrReportNotFound)
+	}
+	return report, nil
+}
+
+// Reconciler runs scheduled reconciliations.
+type Reconciler struct {
+	reconciliationService port.ReconciliationService
+	logger                *logger.Logger
+	config                config.ReconcileConfig
+}
+
+func NewReconciler(reconciliationService port.ReconciliationService, logger *logger.Logger, config config.ReconcileConfig) *Reconciler {
+	return &Reconciler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+		config:                config,
+	}
+}
+
+// Run reconciles every Interval until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.config.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.reconciliationService.Reconcile(ctx, entity.ReconciliationTriggerSchedule, r.config.AutoRepair); err != nil {
+				r.logger.Error(ctx, "Failed to reconcile", "error", err.Error())
+			}
+		}
+	}
+}
@@ -2,11 +2,10 @@ package service
 
 import (
 	"context"
-	"errors"
-	"fmt"
 
 	"github.com/mohammadghasemi1379/sms-gateway/connection"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
@@ -17,7 +16,12 @@ type smsService struct {
 	transactionRepo    port.TransactionRepository
 	rabbitMQConnection *connection.RabbitMQConnection
 	logger             *logger.Logger
-	queueStrategy      *QueueDistributionStrategy
+	outboxRepo         port.OutboxRepository
+	statusPublisher    port.SMSStatusPublisher
+	unitOfWork         port.UnitOfWork
+	ledgerRepo         port.LedgerRepository
+	balanceAlerts      port.BalanceAlertService
+	spendingCaps       port.SpendingCapService
 }
 
 func NewSMSService(
@@ -26,7 +30,12 @@ func NewSMSService(
 	transactionRepo port.TransactionRepository,
 	rabbitMQConnection *connection.RabbitMQConnection,
 	logger *logger.Logger,
-	queueStrategy *QueueDistributionStrategy,
+	outboxRepo port.OutboxRepository,
+	statusPublisher port.SMSStatusPublisher,
+	unitOfWork port.UnitOfWork,
+	ledgerRepo port.LedgerRepository,
+	balanceAlerts port.BalanceAlertService,
+	spendingCaps port.SpendingCapService,
 ) port.SMSService {
 	return &smsService{
 		smsRepo:            smsRepo,
@@ -34,62 +43,90 @@ func NewSMSService(
 		transactionRepo:    transactionRepo,
 		rabbitMQConnection: rabbitMQConnection,
 		logger:             logger,
-		queueStrategy:      queueStrategy,
+		outboxRepo:         outboxRepo,
+		statusPublisher:    statusPublisher,
+		unitOfWork:         unitOfWork,
+		ledgerRepo:         ledgerRepo,
+		balanceAlerts:      balanceAlerts,
+		spendingCaps:       spendingCaps,
 	}
 }
 
 func (s *smsService) SendSMS(ctx context.Context, sms *entity.SMS) error {
 	sms = s.CalculateCost(ctx, sms)
+	if sms.Priority == "" {
+		sms.Priority = entity.SMSPriorityNormal
+	}
 
-	hasEnoughCredit, err := s.userRepo.HasEnoughCredit(ctx, sms.UserID, sms.Cost)
+	user, err := s.userRepo.GetByID(ctx, sms.UserID)
 	if err != nil {
-		s.logger.Error(ctx, "failed to check if user has enough credit", "error", err)
-		return err
+		s.logger.Error(ctx, "failed to get user", "error", err)
+		return errors.ParseDatabaseError(err)
 	}
 
-	if !hasEnoughCredit {
-		return errors.New("user does not have enough credit")
+	if user.IsSuspended() {
+		return errors.ErrUserSuspended
 	}
 
-	err = s.smsRepo.Create(ctx, sms)
-	if err != nil {
-		s.logger.Error(ctx, "failed to create sms", "error", err)
-		return err
-	}
+	// Debit the credit and record the message, its transaction and its
+	// outbox entry together, so a failure can't leave a debit without a
+	// message or a paid message that is never queued. The outbox relay
+	// publishes the message once this commits.
+	var debit *entity.LedgerEntry
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.smsRepo.Create(ctx, sms); err != nil {
+			return err
+		}
 
-	transaction := &entity.Transaction{
-		UserID:    sms.UserID,
-		Amount:    float64(sms.Cost),
-		Status:    entity.TransactionPending,
-		Operation: entity.Decrease,
-		SMSID:     &sms.ID,
-	}
-	err = s.transactionRepo.Create(ctx, transaction)
-	if err != nil {
-		s.logger.Error(ctx, "failed to create transaction", "error", err)
-		return err
-	}
+		if err := s.spendingCaps.Reserve(ctx, user, sms.APIKeyID, int64(sms.Cost)); err != nil {
+			return err
+		}
 
-	user, err := s.userRepo.GetByID(ctx, sms.UserID)
+		entry, err := s.ledgerRepo.Post(ctx, port.LedgerPosting{
+			UserID:        sms.UserID,
+			Type:          entity.LedgerEntryDebit,
+			Amount:        -int64(sms.Cost),
+			ReferenceType: entity.LedgerReferenceSMS,
+			ReferenceID:   sms.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return errors.ErrInsufficientCredit
+		}
+		debit = entry
+
+		err = s.transactionRepo.Create(ctx, &entity.Transaction{
+			UserID:    sms.UserID,
+			Amount:    int64(sms.Cost),
+			Status:    entity.TransactionPending,
+			Operation: entity.Decrease,
+			SMSID:     &sms.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.outboxRepo.Create(ctx, &entity.OutboxMessage{
+			SMSID:     sms.ID,
+			UserID:    sms.UserID,
+			Priority:  sms.Priority,
+			ExpiresAt: sms.ExpiresAt,
+		})
+	})
 	if err != nil {
-		s.logger.Error(ctx, "failed to get user", "error", err)
+		if _, ok := errors.IsBusinessError(err); !ok {
+			s.logger.Error(ctx, "failed to reserve credit for sms", "error", err)
+		}
 		return err
 	}
 
-	err = s.userRepo.DecreaseCredit(ctx, user, sms.Cost)
-	if err != nil {
-		s.logger.Error(ctx, "failed to decrease credit", "error", err)
-		return err
+	if s.balanceAlerts != nil && debit.BalanceAfter != nil {
+		s.balanceAlerts.Check(ctx, sms.UserID, *debit.BalanceAfter)
 	}
 
-    err = s.queueStrategy.PublishToQueue(ctx, connection.RabbitMQMessageBody{
-		Data: fmt.Appendf(nil, "%d", sms.ID),
-		Type: "sms",
-	})
-    if err != nil {
-        s.logger.Error(ctx, "failed to publish sms to queue", "error", err)
-        return err
-    }
+	s.publishStatus(ctx, sms)
 
 	return nil
 }
@@ -105,9 +142,193 @@ func (s *smsService) CalculateCost(ctx context.Context, sms *entity.SMS) *entity
 }
 
 func (s *smsService) GetSMSByID(ctx context.Context, smsID uint64) (*entity.SMS, error) {
-	return s.smsRepo.GetByID(ctx, smsID)
+	sms, err := s.smsRepo.GetByID(ctx, smsID)
+	if err != nil {
+		return nil, errors.ParseDatabaseErrorFor(err, errors.ErrSMSNotFound)
+	}
+	return sms, nil
 }
 
 func (s *smsService) UpdateSMSStatus(ctx context.Context, smsID uint64, status entity.SMSStatusEnum) error {
-	return s.smsRepo.UpdateStatus(ctx, smsID, status)
+	if err := s.smsRepo.UpdateStatus(ctx, smsID, status); err != nil {
+		return err
+	}
+
+	sms, err := s.smsRepo.GetByID(ctx, smsID)
+	if err != nil {
+		s.logger.Error(ctx, "failed to load sms for status event", "error", err, "sms_id", smsID)
+		return nil
+	}
+	s.publishStatus(ctx, sms)
+
+	return nil
+}
+
+func (s *smsService) CancelSMS(ctx context.Context, smsID uint64, userID uint64) (*entity.SMS, error) {
+	sms, err := s.GetSMSByID(ctx, smsID)
+	if err != nil {
+		return nil, err
+	}
+	if sms.UserID != userID {
+		return nil, errors.ErrSMSNotFound
+	}
+
+	cancelled, err := s.closeAndRefund(ctx, smsID, entity.SMSStatusCancelled, "cancelled by user")
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		return nil, errors.ErrSMSNotCancellable
+	}
+
+	return s.GetSMSByID(ctx, smsID)
+}
+
+// ExpireSMS drops a pending SMS whose validity period has passed and refunds
+// its cost. It reports false when the SMS had already left the pending state.
+func (s *smsService) ExpireSMS(ctx context.Context, smsID uint64) (bool, error) {
+	return s.closeAndRefund(ctx, smsID, entity.SMSStatusExpired, "validity period expired")
+}
+
+// FailSMS marks a pending SMS that can't be delivered as FAILED and refunds
+// its cost. It reports false when the SMS had already left the pending state,
+// so redelivered messages are only refunded once.
+func (s *smsService) FailSMS(ctx context.Context, smsID uint64, reason string) (bool, error) {
+	return s.closeAndRefund(ctx, smsID, entity.SMSStatusFailed, reason)
+}
+
+func (s *smsService) RecordAttempt(ctx context.Context, smsID uint64, attempts int, lastError string) error {
+	return s.smsRepo.RecordAttempt(ctx, smsID, attempts, truncate(lastError, maxErrorLength))
+}
+
+// closeAndRefund moves a pending SMS to status, fails its debit transaction
+// and refunds the cost with a compensating INCREASE transaction, all in one
+// unit of work. Only the call that moves the SMS out of PENDING refunds it.
+func (s *smsService) closeAndRefund(ctx context.Context, smsID uint64, status entity.SMSStatusEnum, reason string) (bool, error) {
+	reason = truncate(reason, maxErrorLength)
+
+	var closed *entity.SMS
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		ok, err := s.smsRepo.ClosePending(ctx, smsID, status, reason)
+		if err != nil || !ok {
+			return err
+		}
+
+		sms, err := s.smsRepo.GetByID(ctx, smsID)
+		if err != nil {
+			return err
+		}
+
+		closed = sms
+		// Free system messages, like balance alerts, have nothing to refund
+		// and were never counted against the spending caps.
+		if sms.Cost == 0 {
+			return nil
+		}
+
+		// Give the message back to the spending caps it was counted against.
+		user, err := s.userRepo.GetByID(ctx, sms.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.spendingCaps.Release(ctx, user, sms.APIKeyID, int64(sms.Cost), sms.CreatedAt); err != nil {
+			return err
+		}
+
+		if err := s.transactionRepo.UpdateStatusBySMSID(ctx, smsID, entity.TransactionFailed); err != nil {
+			return err
+		}
+
+		err = s.transactionRepo.Create(ctx, &entity.Transaction{
+			UserID:    sms.UserID,
+			SMSID:     &sms.ID,
+			Amount:    int64(sms.Cost),
+			Operation: entity.Increase,
+			Status:    entity.TransactionSuccess,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = s.ledgerRepo.Post(ctx, port.LedgerPosting{
+			UserID:        sms.UserID,
+			Type:          entity.LedgerEntryRefund,
+			Amount:        int64(sms.Cost),
+			ReferenceType: entity.LedgerReferenceSMS,
+			ReferenceID:   sms.ID,
+			Description:   reason,
+		})
+		return err
+	})
+	if err != nil {
+		s.logger.Error(ctx, "failed to close and refund sms", "error", err, "sms_id", smsID, "status", status)
+		return false, err
+	}
+	if closed == nil {
+		return false, nil
+	}
+
+	s.publishStatus(ctx, closed)
+	return true, nil
+}
+
+func (s *smsService) ClaimSMS(ctx context.Context, smsID uint64, takeover bool) (bool, error) {
+	return s.smsRepo.Claim(ctx, smsID, takeover)
+}
+
+func (s *smsService) ReleaseSMS(ctx context.Context, smsID uint64) error {
+	_, err := s.smsRepo.ReleaseClaim(ctx, smsID)
+	return err
+}
+
+func (s *smsService) CompleteSMS(ctx context.Context, smsID uint64) (bool, error) {
+	var sent *entity.SMS
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		ok, err := s.smsRepo.MarkSent(ctx, smsID)
+		if err != nil || !ok {
+			return err
+		}
+
+		sms, err := s.smsRepo.GetByID(ctx, smsID)
+		if err != nil {
+			return err
+		}
+		sent = sms
+		// Free system messages, like balance alerts, have no debit.
+		if sms.Cost == 0 {
+			return nil
+		}
+
+		settled, err := s.transactionRepo.TransitionBySMSID(ctx, smsID, entity.TransactionPending, entity.TransactionSuccess)
+		if err != nil {
+			return err
+		}
+		if !settled {
+			// Leave the debit for reconciliation to report rather than
+			// losing the record that the message went out.
+			s.logger.Warn(ctx, "debit of sent sms was not pending", "sms_id", smsID)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error(ctx, "failed to complete sms", "error", err, "sms_id", smsID)
+		return false, err
+	}
+	if sent == nil {
+		return false, nil
+	}
+
+	s.publishStatus(ctx, sent)
+	return true, nil
+}
+
+// publishStatus notifies status subscribers. Failures are only logged, since
+// the status change itself has already been stored.
+func (s *smsService) publishStatus(ctx context.Context, sms *entity.SMS) {
+	if s.statusPublisher == nil {
+		return
+	}
+	if err := s.statusPublisher.PublishStatus(ctx, sms); err != nil {
+		s.logger.Error(ctx, "failed to publish sms status event", "error", err, "sms_id", sms.ID)
+	}
 }
\ No newline at end of file
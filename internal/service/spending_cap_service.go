@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+)
+
+type spendingCapService struct {
+	capRepo    port.SpendingCapRepository
+	userRepo   port.UserRepository
+	apiKeyRepo port.APIKeyRepository
+	logger     *logger.Logger
+}
+
+func NewSpendingCapService(
+	capRepo port.SpendingCapRepository,
+	userRepo port.UserRepository,
+	apiKeyRepo port.APIKeyRepository,
+	logger *logger.Logger,
+) port.SpendingCapService {
+	return &spendingCapService{
+		capRepo:    capRepo,
+		userRepo:   userRepo,
+		apiKeyRepo: apiKeyRepo,
+		logger:     logger,
+	}
+}
+
+func (s *spendingCapService) Reserve(ctx context.Context, user *entity.User, apiKeyID *uint64, amount int64) error {
+	var keyID uint64
+	if apiKeyID != nil {
+		keyID = *apiKeyID
+	}
+
+	caps, err := s.capRepo.ListApplicable(ctx, uint64(user.ID), keyID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().In(user.Location())
+	for _, spendingCap := range caps {
+		periodKey := spendingCap.Period.Key(spendingCap.Period.Start(now))
+		added, err := s.capRepo.AddUsage(ctx, spendingCap, periodKey, amount)
+		if err != nil {
+			return err
+		}
+		if !added {
+			return errors.ErrSpendingCapExceeded
+		}
+	}
+	return nil
+}
+
+func (s *spendingCapService) Release(ctx context.Context, user *entity.User, apiKeyID *uint64, amount int64, reservedAt time.Time) error {
+	var keyID uint64
+	if apiKeyID != nil {
+		keyID = *apiKeyID
+	}
+
+	caps, err := s.capRepo.ListApplicable(ctx, uint64(user.ID), keyID)
+	if err != nil {
+		return err
+	}
+
+	// The usage goes back to the period the message was counted in, which
+	// may not be the current one.
+	reservedAt = reservedAt.In(user.Location())
+	for _, spendingCap := range caps {
+		periodKey := spendingCap.Period.Key(spendingCap.Period.Start(reservedAt))
+		if err := s.capRepo.RemoveUsage(ctx, spendingCap, periodKey, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *spendingCapService) ListCaps(ctx context.Context, userID uint64) ([]port.SpendingCapUsage, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	caps, err := s.capRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(user.Location())
+	usages := make([]port.SpendingCapUsage, 0, len(caps))
+	for _, spendingCap := range caps {
+		start := spendingCap.Period.Start(now)
+		usage, err := s.capRepo.GetUsage(ctx, spendingCap, spendingCap.Period.Key(start))
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, port.SpendingCapUsage{
+			SpendingCap:  spendingCap,
+			PeriodStart:  start,
+			ResetsAt:     spendingCap.Period.End(start),
+			UsedAmount:   usage.Amount,
+			UsedMessages: usage.Messages,
+		})
+	}
+	return usages, nil
+}
+
+func (s *spendingCapService) SetCap(ctx context.Context, spendingCap *entity.SpendingCap) error {
+	if spendingCap.APIKeyID != 0 {
+		apiKey, err := s.apiKeyRepo.GetByID(ctx, spendingCap.APIKeyID)
+		if err != nil {
+			return errors.ParseDatabaseErrorFor(err, errors.ErrAPIKeyNotFound)
+		}
+		if apiKey.UserID != spendingCap.UserID {
+			return errors.ErrAPIKeyNotFound
+		}
+	}
+
+	if spendingCap.MaxAmount == nil && spendingCap.MaxMessages == nil {
+		return s.capRepo.Delete(ctx, spendingCap.UserID, spendingCap.APIKeyID, spendingCap.Period)
+	}
+	return s.capRepo.Save(ctx, spendingCap)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/config"
+	"github.com/mohammadghasemi1379/sms-gateway/connection"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	ExchangeSMSStatusEvents = "sms-status-events"
+
+	statusSubscriberBufferSize = 64
+	statusReplayOverlap        = 10 * time.Second
+)
+
+// StatusBroker persists SMS status transitions and fans them out to the SSE
+// subscribers of every gateway instance. Each instance binds its own exclusive
+// queue to the status exchange, so an event published on one instance reaches
+// the subscribers of all of them.
+type StatusBroker struct {
+	eventRepo port.SMSStatusEventRepository
+	logger    *logger.Logger
+	config    config.RabbitMQConfig
+	conn      *connection.RabbitMQConnection
+
+	mu          sync.RWMutex
+	subscribers map[uint64]*port.SMSStatusSubscription
+	nextID      uint64
+}
+
+func NewStatusBroker(
+	eventRepo port.SMSStatusEventRepository,
+	logger *logger.Logger,
+	config config.RabbitMQConfig,
+) *StatusBroker {
+	hostname, _ := os.Hostname()
+	queueName := fmt.Sprintf("%s.%s-%d", ExchangeSMSStatusEvents, hostname, os.Getpid())
+
+	// A single worker keeps events in the order they were delivered, so
+	// subscribers never see a status before the one that preceded it.
+	conn := connection.NewRabbitMQConnection(config, logger, "status-broker", ExchangeSMSStatusEvents, queueName).
+		WithExclusiveQueue().
+		WithWorkerPool(make(chan struct{}, 1))
+
+	return &StatusBroker{
+		eventRepo:   eventRepo,
+		logger:      logger,
+		config:      config,
+		conn:        conn,
+		subscribers: make(map[uint64]*port.SMSStatusSubscription),
+	}
+}
+
+// Run connects to the status exchange and dispatches incoming events to local
+// subscribers until ctx is cancelled.
+func (b *StatusBroker) Run(ctx context.Context) error {
+	if err := b.conn.Connect(); err != nil {
+		return fmt.Errorf("failed to connect status broker: %w", err)
+	}
+	defer b.conn.Close()
+
+	b.conn.ConnectionOpener()
+
+	b.conn.HandleConsumedDeliveries(ctx, b.config.PrefetchCount, func(ctx context.Context, logger *logger.Logger, conn connection.RabbitMQConnection, delivery amqp.Delivery) {
+		var event entity.SMSStatusEvent
+		if err := json.Unmarshal(delivery.Body, &event); err != nil {
+			logger.Error(ctx, "failed to decode sms status event", "error", err.Error())
+		} else {
+			b.dispatch(event)
+		}
+
+		if err := delivery.Ack(false); err != nil {
+			logger.Error(ctx, "failed to ack sms status event", "error", err.Error())
+		}
+	})
+
+	return nil
+}
+
+func (b *StatusBroker) PublishStatus(ctx context.Context, sms *entity.SMS) error {
+	event := &entity.SMSStatusEvent{
+		SMSID:   sms.ID,
+		UserID:  sms.UserID,
+		BatchID: sms.BatchID,
+		Status:  sms.Status,
+	}
+	if err := b.eventRepo.Create(ctx, event); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = b.conn.Publish(ctx, connection.RabbitMQMessage{
+		Exchange:    ExchangeSMSStatusEvents,
+		ContentType: "application/json",
+		Body: connection.RabbitMQMessageBody{
+			Data: data,
+			Type: "sms-status",
+		},
+	})
+	if err != nil {
+		// The event is persisted, so remote subscribers catch up on their next
+		// reconnect; local ones can still be served right away.
+		b.logger.Error(ctx, "failed to fan out sms status event", "error", err.Error(), "sms_id", sms.ID)
+		b.dispatch(*event)
+	}
+
+	return nil
+}
+
+func (b *StatusBroker) Subscribe(filter port.SMSStatusEventFilter) *port.SMSStatusSubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	subscription := &port.SMSStatusSubscription{
+		ID:     b.nextID,
+		Filter: filter,
+		Events: make(chan entity.SMSStatusEvent, statusSubscriberBufferSize),
+	}
+	b.subscribers[subscription.ID] = subscription
+
+	return subscription
+}
+
+func (b *StatusBroker) Unsubscribe(subscription *port.SMSStatusSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[subscription.ID]; ok {
+		delete(b.subscribers, subscription.ID)
+		close(subscription.Events)
+	}
+}
+
+func (b *StatusBroker) Replay(ctx context.Context, filter port.SMSStatusEventFilter, afterID uint64, limit int) ([]entity.SMSStatusEvent, error) {
+	return b.eventRepo.ListAfter(ctx, filter, afterID, limit)
+}
+
+// ReplayStart returns the id a resumed stream replays after. It reaches back
+// statusReplayOverlap before lastEventID to pick up events that committed out
+// of id order, so clients may see an event again and de-duplicate by id.
+func (b *StatusBroker) ReplayStart(ctx context.Context, filter port.SMSStatusEventFilter, lastEventID uint64) (uint64, error) {
+	return b.eventRepo.OverlapStart(ctx, filter, lastEventID, statusReplayOverlap)
+}
+
+func (b *StatusBroker) dispatch(event entity.SMSStatusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id, subscription := range b.subscribers {
+		if subscription.Filter.UserID != event.UserID {
+			continue
+		}
+		if subscription.Filter.BatchID != "" && subscription.Filter.BatchID != event.BatchID {
+			continue
+		}
+
+		select {
+		case subscription.Events <- event:
+		default:
+			// Drop subscribers that can't keep up; they resume from the
+			// database with Last-Event-ID once they reconnect.
+			delete(b.subscribers, id)
+			close(subscription.Events)
+			b.logger.Warn(context.TODO(), "Dropped slow sms status subscriber", "subscription_id", id, "user_id", event.UserID)
+		}
+	}
+}
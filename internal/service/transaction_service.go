@@ -2,26 +2,32 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
 	"github.com/mohammadghasemi1379/sms-gateway/pkg/logger"
 )
 
+const transactionExportBatchSize = 500
+
 type transactionService struct {
 	transactionRepo port.TransactionRepository
 	userRepo        port.UserRepository
+	ledgerRepo      port.LedgerRepository
 	logger *logger.Logger
 }
 
 func NewTransactionService(
 	transactionRepo port.TransactionRepository,
 	userRepo port.UserRepository,
+	ledgerRepo port.LedgerRepository,
 	logger *logger.Logger,
 ) port.TransactionService {
 	return &transactionService{
 		transactionRepo: transactionRepo,
 		userRepo:        userRepo,
+		ledgerRepo:      ledgerRepo,
 		logger: logger,
 	}
 }
@@ -30,3 +36,70 @@ func NewTransactionService(
 func (s *transactionService) UpdateTransactionStatus(ctx context.Context, smsID uint64, status entity.TransactionStatusEnum) error {
 	return s.transactionRepo.UpdateStatusBySMSID(ctx, smsID, status)
 }
+
+func (s *transactionService) ListTransactions(ctx context.Context, filter port.TransactionFilter, cursor uint64, limit int) ([]entity.Transaction, uint64, error) {
+	// Fetch one extra row to know whether there's a next page.
+	transactions, err := s.transactionRepo.List(ctx, filter, cursor, limit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var next uint64
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+		next = transactions[limit-1].ID
+	}
+	return transactions, next, nil
+}
+
+func (s *transactionService) ExportTransactions(ctx context.Context, filter port.TransactionFilter, fn func(batch []entity.Transaction) error) error {
+	var cursor uint64
+	for {
+		batch, err := s.transactionRepo.List(ctx, filter, cursor, transactionExportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < transactionExportBatchSize {
+			return nil
+		}
+		cursor = batch[len(batch)-1].ID
+	}
+}
+
+func (s *transactionService) MonthlyStatement(ctx context.Context, userID uint64, month time.Time) (*port.Statement, error) {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	opening, err := s.ledgerRepo.BalanceBefore(ctx, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	closing, err := s.ledgerRepo.BalanceBefore(ctx, userID, to)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.ledgerRepo.TotalsByType(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &port.Statement{
+		UserID:         userID,
+		Month:          from.Format("2006-01"),
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: closing,
+		TopUps:         totals[entity.LedgerEntryTopUp].Amount + totals[entity.LedgerEntryOpening].Amount,
+		Debits:         -totals[entity.LedgerEntryDebit].Amount,
+		Refunds:        totals[entity.LedgerEntryRefund].Amount,
+		Adjustments:    totals[entity.LedgerEntryAdjustment].Amount,
+		Messages:       totals[entity.LedgerEntryDebit].Entries,
+	}, nil
+}
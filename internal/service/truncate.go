@@ -0,0 +1,20 @@
+package service
+
+// maxErrorLength is the size, in characters, of the VARCHAR columns errors and
+// failure reasons are stored in.
+const maxErrorLength = 255
+
+// truncate shortens s to at most n characters. It cuts between runes, so a
+// multi-byte character is never split.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{name: "short enough", s: "timeout", n: 10, want: "timeout"},
+		{name: "exact length", s: "timeout", n: 7, want: "timeout"},
+		{name: "ascii", s: "connection refused", n: 10, want: "connection"},
+		{name: "multi-byte", s: "ارسال ناموفق", n: 5, want: "ارسال"},
+		{name: "more bytes than runes", s: "خطا", n: 4, want: "خطا"},
+		{name: "zero", s: "error", n: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncate(tt.s, tt.n)
+			if got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q, %d) = %q, not valid UTF-8", tt.s, tt.n, got)
+			}
+		})
+	}
+
+	long := strings.Repeat("é", maxErrorLength+10)
+	if got := utf8.RuneCountInString(truncate(long, maxErrorLength)); got != maxErrorLength {
+		t.Errorf("truncated to %d characters, want %d", got, maxErrorLength)
+	}
+}
@@ -2,24 +2,47 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"time"
 
 	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/errors"
 	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix        = "sk_"
+	webhookSecretPrefix = "whsec_"
 )
 
 type userService struct {
 	userRepo        port.UserRepository
 	transactionRepo port.TransactionRepository
+	apiKeyRepo      port.APIKeyRepository
+	smsRepo         port.SMSRepository
+	ledgerRepo      port.LedgerRepository
+	unitOfWork      port.UnitOfWork
 }
 
 func NewUserService(
 	userRepo port.UserRepository,
 	transactionRepo port.TransactionRepository,
+	apiKeyRepo port.APIKeyRepository,
+	smsRepo port.SMSRepository,
+	ledgerRepo port.LedgerRepository,
+	unitOfWork port.UnitOfWork,
 ) port.UserService {
 	return &userService{
 		userRepo:        userRepo,
 		transactionRepo: transactionRepo,
+		apiKeyRepo:      apiKeyRepo,
+		smsRepo:         smsRepo,
+		ledgerRepo:      ledgerRepo,
+		unitOfWork:      unitOfWork,
 	}
 }
 
@@ -29,19 +52,253 @@ func (s *userService) CreateUser(ctx context.Context, user *entity.User) (*entit
 		return nil, errors.ParseDatabaseError(err)
 	}
 
+	key, err := s.issueAPIKey(ctx, uint64(user.ID), "default")
+	if err != nil {
+		return nil, err
+	}
+	user.APIKey = key
+
 	return user, nil
 }
 
+// UpdateCredit tops up the user's credit, recording the top-up as a
+// transaction and in the ledger.
 func (s *userService) UpdateCredit(ctx context.Context, userID uint64, amount uint32) (*entity.User, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		transaction := &entity.Transaction{
+			UserID:    userID,
+			Amount:    int64(amount),
+			Operation: entity.Increase,
+			Status:    entity.TransactionSuccess,
+		}
+		if err := s.transactionRepo.Create(ctx, transaction); err != nil {
+			return err
+		}
+
+		_, err := s.ledgerRepo.Post(ctx, port.LedgerPosting{
+			UserID:        userID,
+			Type:          entity.LedgerEntryTopUp,
+			Amount:        int64(amount),
+			ReferenceType: entity.LedgerReferenceTransaction,
+			ReferenceID:   transaction.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	return user, nil
+}
+
+func (s *userService) AuthenticateAPIKey(ctx context.Context, key string) (*entity.User, *entity.APIKey, error) {
+	apiKey, err := s.apiKeyRepo.GetActiveByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if stderrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.ErrUnauthorized
+		}
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, nil, errors.ParseDatabaseError(err)
+	}
+
+	return user, apiKey, nil
+}
+
+func (s *userService) ListUsers(ctx context.Context, filter port.UserFilter, page int, pageSize int) ([]entity.User, int64, error) {
+	return s.userRepo.Search(ctx, filter, pageSize, (page-1)*pageSize)
+}
+
+func (s *userService) GetUserDetails(ctx context.Context, userID uint64) (*port.UserDetails, error) {
 	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	usage, err := s.smsRepo.UsageByUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.userRepo.IncreaseCredit(ctx, user, amount)
+	return &port.UserDetails{User: user, Usage: usage}, nil
+}
+
+func (s *userService) SuspendUser(ctx context.Context, userID uint64, reason string) (*entity.User, error) {
+	return s.updateUser(ctx, userID, map[string]any{
+		"status":            entity.UserStatusSuspended,
+		"suspension_reason": reason,
+		"suspended_at":      time.Now(),
+	})
+}
+
+func (s *userService) ReactivateUser(ctx context.Context, userID uint64) (*entity.User, error) {
+	return s.updateUser(ctx, userID, map[string]any{
+		"status":            entity.UserStatusActive,
+		"suspension_reason": "",
+		"suspended_at":      nil,
+	})
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, userID uint64, update port.UserProfileUpdate) (*entity.User, error) {
+	fields := make(map[string]any)
+	if update.Name != nil {
+		fields["name"] = *update.Name
+	}
+	if update.PhoneNumber != nil {
+		fields["phone_number"] = *update.PhoneNumber
+	}
+	if len(fields) == 0 {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Nothing to update")
+	}
+
+	return s.updateUser(ctx, userID, fields)
+}
+
+func (s *userService) UpdateLimits(ctx context.Context, userID uint64, limits port.UserLimits) (*entity.User, error) {
+	return s.updateUser(ctx, userID, map[string]any{
+		"plan":       limits.Plan,
+		"rate_limit": limits.RateLimit,
+	})
+}
+
+// UpdateBilling switches the account between prepaid and postpaid. Lowering
+// the limit below what a postpaid user already owes is allowed; it only
+// blocks further debits until the balance is back within the limit. A user
+// who still owes money can't become prepaid, as the debt would drop out of
+// the outstanding balances report.
+func (s *userService) UpdateBilling(ctx context.Context, userID uint64, billing port.UserBilling) (*entity.User, error) {
+	if billing.AccountType == entity.AccountTypePrepaid && billing.CreditLimit != 0 {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Prepaid accounts can't have a credit limit")
+	}
+
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	updated, err := s.userRepo.UpdateBilling(ctx, userID, billing.AccountType, billing.CreditLimit)
 	if err != nil {
 		return nil, errors.ParseDatabaseError(err)
 	}
 
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	// Nothing is updated either when the values were already set or when the
+	// user still owes money; only the latter is an error.
+	if !updated && user.AccountType != billing.AccountType {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Outstanding balance must be settled before switching to prepaid")
+	}
 	return user, nil
 }
+
+func (s *userService) OutstandingBalances(ctx context.Context) (*port.OutstandingReport, error) {
+	users, err := s.userRepo.ListOutstanding(ctx)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	report := &port.OutstandingReport{Accounts: make([]port.OutstandingBalance, 0, len(users))}
+	for _, user := range users {
+		report.Accounts = append(report.Accounts, port.OutstandingBalance{
+			UserID:      user.ID,
+			Name:        user.Name,
+			PhoneNumber: user.PhoneNumber,
+			Credit:      user.Credit,
+			CreditLimit: user.CreditLimit,
+			Outstanding: -user.Credit,
+			Available:   max(user.Credit-user.MinimumBalance(), 0),
+		})
+		report.TotalOutstanding -= user.Credit
+	}
+	return report, nil
+}
+
+func (s *userService) SetWebhook(ctx context.Context, userID uint64, url string) (*entity.User, string, error) {
+	var secret string
+	if url != "" {
+		if err := validateWebhookURL(url); err != nil {
+			return nil, "", errors.NewBusinessError(errors.CodeInvalidInput, "Invalid webhook url: "+err.Error())
+		}
+
+		raw := make([]byte, 24)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, "", err
+		}
+		secret = webhookSecretPrefix + hex.EncodeToString(raw)
+	}
+
+	user, err := s.updateUser(ctx, userID, map[string]any{
+		"webhook_url":    url,
+		"webhook_secret": secret,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return user, secret, nil
+}
+
+func (s *userService) SetTimezone(ctx context.Context, userID uint64, timezone string) (*entity.User, error) {
+	if _, err := time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+		return nil, errors.NewBusinessError(errors.CodeInvalidInput, "Unknown timezone")
+	}
+
+	return s.updateUser(ctx, userID, map[string]any{
+		"timezone": timezone,
+	})
+}
+
+// updateUser applies fields to an existing user and returns the updated user.
+func (s *userService) updateUser(ctx context.Context, userID uint64, fields map[string]any) (*entity.User, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	if err := s.userRepo.Update(ctx, userID, fields); err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.ParseDatabaseError(err)
+	}
+	return user, nil
+}
+
+// issueAPIKey creates a new API key for the user and returns the plain key.
+// Only its hash is stored, so the plain key can't be shown again later.
+func (s *userService) issueAPIKey(ctx context.Context, userID uint64, name string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey := &entity.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  key[:len(apiKeyPrefix)+8],
+		KeyHash: hashAPIKey(key),
+	}
+	if err := s.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mohammadghasemi1379/sms-gateway/internal/entity"
+	"github.com/mohammadghasemi1379/sms-gateway/internal/port"
+)
+
+// errWebhookNotPublic rejects webhooks pointing into the gateway's own network,
+// like loopback, private or link-local addresses such as the cloud metadata
+// endpoint.
+var errWebhookNotPublic = stderrors.New("webhook must point to a public address")
+
+// sharedAddressSpace is the carrier-grade NAT range, which IsPrivate leaves
+// out.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+type webhookSender struct {
+	client *http.Client
+}
+
+// NewWebhookSender returns a sender that only connects to public addresses.
+// The address is checked once resolved, when dialing, so a hostname can't
+// point somewhere else after its URL was accepted. Redirects aren't followed.
+func NewWebhookSender(timeout time.Duration) port.WebhookSender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: checkWebhookDial,
+	}
+	return &webhookSender{
+		client: &http.Client{
+			Timeout: timeout,
+			// No proxy, so the dialed address is the webhook's own.
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				ForceAttemptHTTP2:   true,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// validateWebhookURL accepts https URLs whose host isn't obviously internal.
+// Hostnames are checked again once resolved, by checkWebhookDial.
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" {
+		return stderrors.New("webhook must use https")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return stderrors.New("webhook has no host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errWebhookNotPublic
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return errWebhookNotPublic
+	}
+	return nil
+}
+
+// checkWebhookDial is the dialer's Control hook, run for every address a
+// webhook connection is about to be made to.
+func checkWebhookDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", errWebhookNotPublic, addr)
+	}
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+type webhookEnvelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Send posts the payload to the user's webhook. The body is signed with the
+// user's webhook secret: X-Webhook-Signature is the hex HMAC-SHA256 of
+// "<X-Webhook-Timestamp>.<body>". Any non-2xx response is an error.
+func (w *webhookSender) Send(ctx context.Context, user *entity.User, event string, payload any) error {
+	if user.WebhookURL == "" {
+		return fmt.Errorf("user %d has no webhook", user.ID)
+	}
+	// Webhooks set before URLs were checked are held to the same rules.
+	if err := validateWebhookURL(user.WebhookURL); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(webhookEnvelope{
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(user.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, user.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", hex.EncodeToString(mac.Sum(nil)))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
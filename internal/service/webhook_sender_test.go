@@ -0,0 +1,60 @@
+package service
+
+import "testing"
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "https://example.com/hooks/sms", wantErr: false},
+		{url: "https://93.184.216.34:8443/hook", wantErr: false},
+		{url: "http://example.com/hook", wantErr: true},
+		{url: "ftp://example.com/hook", wantErr: true},
+		{url: "https:///hook", wantErr: true},
+		{url: "https://localhost/hook", wantErr: true},
+		{url: "https://api.localhost/hook", wantErr: true},
+		{url: "https://127.0.0.1/hook", wantErr: true},
+		{url: "https://10.0.0.8/hook", wantErr: true},
+		{url: "https://192.168.1.1/hook", wantErr: true},
+		{url: "https://169.254.169.254/latest/meta-data", wantErr: true},
+		{url: "https://100.100.100.200/hook", wantErr: true},
+		{url: "https://[::1]/hook", wantErr: true},
+		{url: "https://[fd00::1]/hook", wantErr: true},
+		{url: "https://[::ffff:127.0.0.1]/hook", wantErr: true},
+		{url: "https://0.0.0.0/hook", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := validateWebhookURL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateWebhookURL(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookDial(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{address: "93.184.216.34:443", wantErr: false},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443", wantErr: false},
+		{address: "127.0.0.1:443", wantErr: true},
+		{address: "172.16.5.4:443", wantErr: true},
+		{address: "169.254.169.254:80", wantErr: true},
+		{address: "[fe80::1]:443", wantErr: true},
+		{address: "[::ffff:10.1.2.3]:443", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := checkWebhookDial("tcp", tt.address, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkWebhookDial(%q) = %v, want error %v", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
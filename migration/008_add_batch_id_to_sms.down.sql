@@ -0,0 +1,3 @@
+ALTER TABLE sms
+DROP INDEX idx_sms_user_id_batch_id,
+DROP COLUMN batch_id;
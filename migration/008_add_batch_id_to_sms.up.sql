@@ -0,0 +1,3 @@
+ALTER TABLE sms
+ADD COLUMN batch_id VARCHAR(64) NOT NULL DEFAULT '' AFTER user_id,
+ADD INDEX idx_sms_user_id_batch_id (user_id, batch_id);
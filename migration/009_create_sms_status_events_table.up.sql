@@ -0,0 +1,13 @@
+CREATE TABLE sms_status_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sms_id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    batch_id VARCHAR(64) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (sms_id) REFERENCES sms(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_sms_status_events_user_id (user_id, id),
+    INDEX idx_sms_status_events_batch_id (user_id, batch_id, id)
+);
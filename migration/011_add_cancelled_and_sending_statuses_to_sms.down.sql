@@ -0,0 +1,4 @@
+UPDATE sms SET status = 'PENDING' WHERE status = 'SENDING';
+UPDATE sms SET status = 'FAILED' WHERE status = 'CANCELLED';
+ALTER TABLE sms
+MODIFY COLUMN status ENUM('PENDING', 'SENT', 'FAILED') NOT NULL DEFAULT 'PENDING';
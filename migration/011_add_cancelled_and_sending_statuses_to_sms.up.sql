@@ -0,0 +1,2 @@
+ALTER TABLE sms
+MODIFY COLUMN status ENUM('PENDING', 'SENDING', 'SENT', 'FAILED', 'CANCELLED') NOT NULL DEFAULT 'PENDING';
@@ -0,0 +1,6 @@
+ALTER TABLE users
+DROP INDEX idx_users_status,
+DROP COLUMN suspended_at,
+DROP COLUMN suspension_reason,
+DROP COLUMN status,
+DROP COLUMN rate_limit;
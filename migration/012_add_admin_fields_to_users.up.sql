@@ -0,0 +1,6 @@
+ALTER TABLE users
+ADD COLUMN rate_limit INT UNSIGNED NULL DEFAULT NULL AFTER plan,
+ADD COLUMN status ENUM('ACTIVE', 'SUSPENDED') NOT NULL DEFAULT 'ACTIVE' AFTER rate_limit,
+ADD COLUMN suspension_reason VARCHAR(255) NOT NULL DEFAULT '' AFTER status,
+ADD COLUMN suspended_at TIMESTAMP NULL DEFAULT NULL AFTER suspension_reason,
+ADD INDEX idx_users_status (status);
@@ -0,0 +1 @@
+ALTER TABLE sms DROP COLUMN priority;
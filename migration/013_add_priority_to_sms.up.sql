@@ -0,0 +1,2 @@
+ALTER TABLE sms
+ADD COLUMN priority ENUM('NORMAL', 'HIGH') NOT NULL DEFAULT 'NORMAL' AFTER status;
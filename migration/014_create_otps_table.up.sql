@@ -0,0 +1,17 @@
+CREATE TABLE otps (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    receive_number VARCHAR(20) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    max_attempts INT UNSIGNED NOT NULL,
+    sms_id INT UNSIGNED NULL,
+    expires_at TIMESTAMP NOT NULL,
+    verified_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sms_id) REFERENCES sms(id) ON DELETE SET NULL,
+    INDEX idx_otps_user_id_receive_number (user_id, receive_number, id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE users
+DROP COLUMN webhook_secret,
+DROP COLUMN webhook_url;
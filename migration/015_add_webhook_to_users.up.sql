@@ -0,0 +1,3 @@
+ALTER TABLE users
+ADD COLUMN webhook_url VARCHAR(2048) NOT NULL DEFAULT '' AFTER suspended_at,
+ADD COLUMN webhook_secret VARCHAR(128) NOT NULL DEFAULT '' AFTER webhook_url;
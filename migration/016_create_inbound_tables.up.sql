@@ -0,0 +1,35 @@
+CREATE TABLE inbound_routes (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    number VARCHAR(20) NOT NULL,
+    keyword VARCHAR(32) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_inbound_routes_number_keyword (number, keyword)
+);
+
+CREATE TABLE inbound_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NULL,
+    provider VARCHAR(64) NOT NULL,
+    provider_message_id VARCHAR(128) NOT NULL,
+    from_number VARCHAR(20) NOT NULL,
+    to_number VARCHAR(20) NOT NULL,
+    keyword VARCHAR(32) NOT NULL DEFAULT '',
+    message TEXT NOT NULL,
+    forward_status ENUM('PENDING', 'DELIVERED', 'FAILED', 'SKIPPED') NOT NULL DEFAULT 'PENDING',
+    forward_attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    forward_error VARCHAR(255) NOT NULL DEFAULT '',
+    next_forward_at TIMESTAMP NULL DEFAULT NULL,
+    forwarded_at TIMESTAMP NULL DEFAULT NULL,
+    received_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE INDEX idx_inbound_messages_provider_message (provider, provider_message_id),
+    INDEX idx_inbound_messages_user_id (user_id, id),
+    INDEX idx_inbound_messages_forward (forward_status, next_forward_at)
+);
@@ -0,0 +1,5 @@
+UPDATE sms SET status = 'FAILED' WHERE status = 'EXPIRED';
+ALTER TABLE sms
+DROP INDEX idx_sms_status_expires_at,
+DROP COLUMN expires_at,
+MODIFY COLUMN status ENUM('PENDING', 'SENDING', 'SENT', 'FAILED', 'CANCELLED') NOT NULL DEFAULT 'PENDING';
@@ -0,0 +1,4 @@
+ALTER TABLE sms
+MODIFY COLUMN status ENUM('PENDING', 'SENDING', 'SENT', 'FAILED', 'CANCELLED', 'EXPIRED') NOT NULL DEFAULT 'PENDING',
+ADD COLUMN expires_at TIMESTAMP NULL DEFAULT NULL AFTER cost,
+ADD INDEX idx_sms_status_expires_at (status, expires_at);
@@ -0,0 +1 @@
+ALTER TABLE sms DROP COLUMN failure_reason;
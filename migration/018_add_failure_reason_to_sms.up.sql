@@ -0,0 +1,2 @@
+ALTER TABLE sms
+ADD COLUMN failure_reason VARCHAR(255) NOT NULL DEFAULT '' AFTER expires_at;
@@ -0,0 +1,6 @@
+ALTER TABLE transactions
+MODIFY COLUMN amount DECIMAL(15,2) NOT NULL;
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable_delete;
+DROP TRIGGER IF EXISTS ledger_entries_immutable_update;
+DROP TABLE IF EXISTS ledger_entries;
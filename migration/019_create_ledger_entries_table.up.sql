@@ -0,0 +1,36 @@
+CREATE TABLE ledger_entries (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    journal_id VARCHAR(64) NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    user_id INT UNSIGNED NULL,
+    type ENUM('OPENING', 'TOPUP', 'DEBIT', 'REFUND', 'ADJUSTMENT') NOT NULL,
+    amount BIGINT NOT NULL,
+    balance_after BIGINT NULL,
+    reference_type VARCHAR(32) NOT NULL DEFAULT '',
+    reference_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT,
+    INDEX idx_ledger_entries_journal_id (journal_id),
+    INDEX idx_ledger_entries_user_id (user_id, id),
+    INDEX idx_ledger_entries_account (account, id),
+    INDEX idx_ledger_entries_reference (reference_type, reference_id)
+);
+
+CREATE TRIGGER ledger_entries_immutable_update BEFORE UPDATE ON ledger_entries
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger entries are immutable';
+
+CREATE TRIGGER ledger_entries_immutable_delete BEFORE DELETE ON ledger_entries
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'ledger entries are immutable';
+
+INSERT INTO ledger_entries (journal_id, account, user_id, type, amount, balance_after, reference_type, description)
+SELECT CONCAT('opening-', id), CONCAT('user:', id), id, 'OPENING', credit, credit, 'admin', 'Opening balance'
+FROM users WHERE credit <> 0;
+
+INSERT INTO ledger_entries (journal_id, account, user_id, type, amount, balance_after, reference_type, description)
+SELECT CONCAT('opening-', id), 'system:opening', NULL, 'OPENING', -credit, NULL, 'admin', 'Opening balance'
+FROM users WHERE credit <> 0;
+
+ALTER TABLE transactions
+MODIFY COLUMN amount BIGINT NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS balance_alerts;
+DROP TABLE IF EXISTS balance_alert_settings;
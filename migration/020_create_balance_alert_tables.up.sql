@@ -0,0 +1,27 @@
+CREATE TABLE balance_alert_settings (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    threshold BIGINT NOT NULL DEFAULT 0,
+    notify_sms BOOLEAN NOT NULL DEFAULT FALSE,
+    notify_webhook BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    last_alerted_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE balance_alerts (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    threshold BIGINT NOT NULL,
+    balance BIGINT NOT NULL,
+    sms_id INT UNSIGNED NULL,
+    webhook_status ENUM('SENT', 'FAILED', 'SKIPPED') NULL,
+    webhook_error VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sms_id) REFERENCES sms(id) ON DELETE SET NULL,
+    INDEX idx_balance_alerts_user_id (user_id, id)
+);
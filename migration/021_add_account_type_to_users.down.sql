@@ -0,0 +1,4 @@
+ALTER TABLE users
+DROP INDEX idx_users_account_type,
+DROP COLUMN credit_limit,
+DROP COLUMN account_type;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+ADD COLUMN account_type ENUM('PREPAID', 'POSTPAID') NOT NULL DEFAULT 'PREPAID' AFTER credit,
+ADD COLUMN credit_limit BIGINT NOT NULL DEFAULT 0 AFTER account_type,
+ADD INDEX idx_users_account_type (account_type);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reconciliation_findings;
+DROP TABLE IF EXISTS reconciliation_reports;
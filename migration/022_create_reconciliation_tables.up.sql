@@ -0,0 +1,25 @@
+CREATE TABLE reconciliation_reports (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    triggered_by ENUM('SCHEDULE', 'ADMIN', 'CLI') NOT NULL,
+    repair BOOLEAN NOT NULL DEFAULT FALSE,
+    issues INT NOT NULL DEFAULT 0,
+    repaired INT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX idx_reconciliation_reports_started_at (started_at)
+);
+
+CREATE TABLE reconciliation_findings (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    report_id BIGINT UNSIGNED NOT NULL,
+    kind ENUM('ORPHANED_TRANSACTION', 'MISSING_TRANSACTION', 'STATUS_MISMATCH', 'MISSING_REFUND', 'BALANCE_DRIFT') NOT NULL,
+    user_id INT UNSIGNED NULL,
+    sms_id INT UNSIGNED NULL,
+    transaction_id INT UNSIGNED NULL,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    repaired BOOLEAN NOT NULL DEFAULT FALSE,
+
+    FOREIGN KEY (report_id) REFERENCES reconciliation_reports(id) ON DELETE CASCADE,
+    INDEX idx_reconciliation_findings_report_id (report_id)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS spending_usage;
+DROP TABLE IF EXISTS spending_caps;
+
+ALTER TABLE sms
+DROP FOREIGN KEY sms_ibfk_2,
+DROP COLUMN api_key_id;
+
+ALTER TABLE users
+DROP COLUMN timezone;
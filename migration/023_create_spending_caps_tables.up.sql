@@ -0,0 +1,32 @@
+ALTER TABLE users
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC' AFTER suspended_at;
+
+ALTER TABLE sms
+ADD COLUMN api_key_id INT UNSIGNED NULL AFTER user_id,
+ADD FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE SET NULL;
+
+CREATE TABLE spending_caps (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    api_key_id INT UNSIGNED NOT NULL DEFAULT 0,
+    period ENUM('DAILY', 'MONTHLY') NOT NULL,
+    max_amount BIGINT NULL,
+    max_messages BIGINT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_spending_caps_scope (user_id, api_key_id, period)
+);
+
+CREATE TABLE spending_usage (
+    user_id INT UNSIGNED NOT NULL,
+    api_key_id INT UNSIGNED NOT NULL DEFAULT 0,
+    period ENUM('DAILY', 'MONTHLY') NOT NULL,
+    period_key VARCHAR(10) NOT NULL,
+    amount BIGINT NOT NULL DEFAULT 0,
+    messages BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (user_id, api_key_id, period, period_key),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+UPDATE sms SET priority = 'NORMAL' WHERE priority = 'LOW';
+
+ALTER TABLE sms
+MODIFY COLUMN priority ENUM('NORMAL', 'HIGH') NOT NULL DEFAULT 'NORMAL';
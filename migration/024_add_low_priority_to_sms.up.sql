@@ -0,0 +1,2 @@
+ALTER TABLE sms
+MODIFY COLUMN priority ENUM('LOW', 'NORMAL', 'HIGH') NOT NULL DEFAULT 'NORMAL';
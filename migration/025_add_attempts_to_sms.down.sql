@@ -0,0 +1,3 @@
+ALTER TABLE sms
+DROP COLUMN last_error,
+DROP COLUMN attempts;
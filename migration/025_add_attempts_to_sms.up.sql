@@ -0,0 +1,3 @@
+ALTER TABLE sms
+ADD COLUMN attempts INT NOT NULL DEFAULT 0 AFTER failure_reason,
+ADD COLUMN last_error VARCHAR(255) NOT NULL DEFAULT '' AFTER attempts;
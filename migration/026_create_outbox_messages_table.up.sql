@@ -0,0 +1,15 @@
+CREATE TABLE outbox_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sms_id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    priority ENUM('LOW', 'NORMAL', 'HIGH') NOT NULL DEFAULT 'NORMAL',
+    expires_at TIMESTAMP NULL DEFAULT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error VARCHAR(255) NOT NULL DEFAULT '',
+    available_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (sms_id) REFERENCES sms(id) ON DELETE CASCADE,
+    INDEX idx_outbox_messages_due (dispatched_at, available_at, id)
+);
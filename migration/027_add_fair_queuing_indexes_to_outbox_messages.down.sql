@@ -0,0 +1,3 @@
+ALTER TABLE outbox_messages
+    DROP INDEX idx_outbox_messages_user_due,
+    DROP INDEX idx_outbox_messages_priority_due;
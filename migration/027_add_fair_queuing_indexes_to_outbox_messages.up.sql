@@ -0,0 +1,3 @@
+ALTER TABLE outbox_messages
+    ADD INDEX idx_outbox_messages_user_due (user_id, dispatched_at, available_at, id),
+    ADD INDEX idx_outbox_messages_priority_due (priority, dispatched_at, available_at, id);